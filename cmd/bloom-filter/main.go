@@ -0,0 +1,91 @@
+// Command bloom-filter builds the breached passwords filter that is loaded with
+// -breached-passwords-filter from a Have I Been Pwned SHA-1 download.
+//
+//	go run ./cmd/bloom-filter -in pwned-passwords-sha1.txt -out breached.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+// countLines counts the hashes up front, so the filter can be sized for them.
+func countLines(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var n uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+
+	return n, scanner.Err()
+}
+
+func build(in io.Reader, out io.Writer, n uint64, fpRate float64) (uint64, error) {
+	b := validators.NewBloomFilterFor(n, fpRate)
+
+	added, err := b.AddHashes(in)
+	if err != nil {
+		return added, err
+	}
+
+	w := bufio.NewWriter(out)
+	if _, err := b.WriteTo(w); err != nil {
+		return added, err
+	}
+
+	return added, w.Flush()
+}
+
+func main() {
+	var (
+		fIn     = flag.String("in", "", "Path of the Have I Been Pwned SHA-1 download, one HASH:COUNT line per password.")
+		fOut    = flag.String("out", "breached.bloom", "Path to write the filter to.")
+		fFPRate = flag.Float64("fp-rate", 0.001, "False positive rate, the probability that a password that was never breached is rejected.")
+	)
+	flag.Parse()
+
+	if *fIn == "" {
+		log.Fatal("err: -in is required")
+	}
+	if *fFPRate <= 0 || *fFPRate >= 1 {
+		log.Fatal("err: -fp-rate must be between 0 and 1")
+	}
+
+	n, err := countLines(*fIn)
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+
+	in, err := os.Open(*fIn)
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(*fOut)
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+
+	added, err := build(in, out, n, *fFPRate)
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("err: %v", err)
+	}
+
+	fmt.Printf("wrote %d hashes to %s\n", added, *fOut)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+func TestBuildRoundTrip(t *testing.T) {
+	breached := []string{"Password1!", "Summer2024!", "qwertz"}
+
+	var download strings.Builder
+	for i, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		fmt.Fprintf(&download, "%s:%d\r\n", strings.ToUpper(hex.EncodeToString(sum[:])), i+1)
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := build(strings.NewReader(download.String()), out, uint64(len(breached)), 0.001)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Close()
+
+	if added != uint64(len(breached)) {
+		t.Errorf("expected %d hashes to be added, got %d", len(breached), added)
+	}
+
+	filter, err := validators.LoadBloomFilter(path)
+	if err != nil {
+		t.Fatalf("could not load the filter: %v", err)
+	}
+
+	for _, password := range breached {
+		if !filter.MayContain(password) {
+			t.Errorf("expected %q to be in the filter", password)
+		}
+	}
+	if filter.MayContain("correct horse battery staple") {
+		t.Error("expected a password that wasn't added to not be in the filter")
+	}
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ActionChangePassword = "change-password"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is a single audit record. It must never contain a password.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"`
+	Username  string    `json:"username"`
+	ClientIP  string    `json:"clientIp"`
+	RequestID string    `json:"requestId,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type Auditor interface {
+	Log(event Event)
+}
+
+// Nop discards all events, it is used when auditing is disabled.
+type Nop struct{}
+
+func (Nop) Log(Event) {}
+
+// FileAuditor appends events as JSON lines to a file.
+type FileAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewFileAuditor(path string) (*FileAuditor, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditor{enc: json.NewEncoder(f)}, nil
+}
+
+func (a *FileAuditor) Log(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.enc.Encode(event); err != nil {
+		slog.Error("could not write audit event", "err", err)
+	}
+}
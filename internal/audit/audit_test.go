@@ -0,0 +1,46 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/audit"
+)
+
+func TestFileAuditor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := audit.NewFileAuditor(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a.Log(audit.Event{Action: audit.ActionChangePassword, Username: "jdoe", ClientIP: "192.0.2.1", Outcome: audit.OutcomeSuccess})
+	a.Log(audit.Event{Action: audit.ActionChangePassword, Username: "jdoe", ClientIP: "192.0.2.1", Outcome: audit.OutcomeFailure, Reason: "invalid credentials"})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("could not parse line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	if events[1].Outcome != audit.OutcomeFailure || events[1].Reason != "invalid credentials" || events[1].Timestamp.IsZero() {
+		t.Errorf("unexpected event %+v", events[1])
+	}
+}
@@ -0,0 +1,85 @@
+package check
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/web/templates"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// Check is a single step of the startup check.
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+// Config makes sure the options are consistent with each other.
+func Config(opts *options.Opts) Check {
+	return Check{Name: "config", Run: opts.Validate}
+}
+
+// LDAP binds to every configured LDAP server with the readonly user.
+func LDAP(opts *options.Opts) []Check {
+	var checks []Check
+	for _, server := range strings.Split(opts.LDAP.Server, ",") {
+		config := opts.LDAP
+		config.Server = strings.TrimSpace(server)
+
+		checks = append(checks, Check{
+			Name: fmt.Sprintf("ldap bind to %s as %s", config.Server, opts.ReadonlyUser),
+			Run: func() error {
+				_, err := ldap.New(config, opts.ReadonlyUser, opts.ReadonlyPassword)
+				return err
+			},
+		})
+	}
+
+	return checks
+}
+
+// Templates renders the pages served by the application.
+func Templates(opts *options.Opts) Check {
+	return Check{
+		Name: "render templates",
+		Run: func() error {
+			_, err := templates.RenderIndex(opts)
+			return err
+		},
+	}
+}
+
+// All returns every check of the configured dependencies.
+func All(opts *options.Opts) []Check {
+	checks := []Check{Config(opts)}
+	checks = append(checks, LDAP(opts)...)
+
+	return append(checks, Templates(opts))
+}
+
+// Run runs all checks, even after one of them failed, prints a line with the
+// outcome of each to w and reports whether all of them passed.
+func Run(w io.Writer, checks []Check) bool {
+	failed := 0
+	for _, check := range checks {
+		if err := check.Run(); err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %v\n", check.Name, err)
+
+			continue
+		}
+
+		fmt.Fprintf(w, "ok   %s\n", check.Name)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d checks failed\n", failed, len(checks))
+		return false
+	}
+
+	fmt.Fprintf(w, "all %d checks passed\n", len(checks))
+
+	return true
+}
@@ -0,0 +1,73 @@
+package check_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/check"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	ran := 0
+
+	ok := check.Run(&out, []check.Check{
+		{Name: "first", Run: func() error { ran++; return errors.New("broken") }},
+		{Name: "second", Run: func() error { ran++; return nil }},
+	})
+
+	if ok {
+		t.Error("expected the checks to fail")
+	}
+	if ran != 2 {
+		t.Errorf("expected all checks to run, %d ran", ran)
+	}
+
+	expected := "FAIL first: broken\nok   second\n1 of 2 checks failed\n"
+	if out.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, out.String())
+	}
+}
+
+func TestRunPasses(t *testing.T) {
+	var out bytes.Buffer
+
+	if !check.Run(&out, []check.Check{{Name: "only", Run: func() error { return nil }}}) {
+		t.Errorf("expected the checks to pass, got %q", out.String())
+	}
+}
+
+func TestConfig(t *testing.T) {
+	opts := &options.Opts{MinLength: 12, MaxLength: 8}
+	if err := check.Config(opts).Run(); err == nil {
+		t.Error("expected inconsistent options to fail the check")
+	}
+
+	opts.MaxLength = 64
+	if err := check.Config(opts).Run(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	if err := check.Templates(&options.Opts{MinLength: 8, MaxLength: 64}).Run(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLDAPChecksEveryServer(t *testing.T) {
+	opts := &options.Opts{ReadonlyUser: "readonly"}
+	opts.LDAP.Server = "ldaps://dc1.example.com, ldaps://dc2.example.com"
+
+	checks := check.LDAP(opts)
+	if len(checks) != 2 {
+		t.Fatalf("expected 2 checks, got %d", len(checks))
+	}
+
+	expected := "ldap bind to ldaps://dc2.example.com as readonly"
+	if checks[1].Name != expected {
+		t.Errorf("expected name %q, got %q", expected, checks[1].Name)
+	}
+}
@@ -0,0 +1,103 @@
+package cooldown
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracker remembers when the password of an account was last changed, so
+// the next change can be delayed. Unlike the lockout it only counts
+// successful changes.
+type Tracker struct {
+	mu       sync.Mutex
+	duration time.Duration
+	changes  map[string]time.Time
+}
+
+func New(duration time.Duration) *Tracker {
+	return &Tracker{
+		duration: duration,
+		changes:  make(map[string]time.Time),
+	}
+}
+
+func key(account string) string {
+	return strings.ToLower(account)
+}
+
+func (t *Tracker) RecordChange(account string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.changes[key(account)] = at
+}
+
+// Remaining returns how long the account has to wait until its password can
+// be changed again, or 0 if it can be changed now.
+func (t *Tracker) Remaining(account string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.changes[key(account)]
+	if !ok {
+		return 0
+	}
+
+	if remaining := last.Add(t.duration).Sub(now); remaining > 0 {
+		return remaining
+	}
+
+	return 0
+}
+
+// Count returns the amount of accounts that were changed recently.
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.changes)
+}
+
+// CleanupExpired forgets accounts whose cooldown is over, and returns how many
+// were removed.
+func (t *Tracker) CleanupExpired() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for k, last := range t.changes {
+		if now.Sub(last) >= t.duration {
+			delete(t.changes, k)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// StartCleanup periodically calls CleanupExpired until the returned function
+// is called.
+func (t *Tracker) StartCleanup(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				t.CleanupExpired()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
@@ -0,0 +1,41 @@
+package cooldown_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/cooldown"
+)
+
+func TestBlocksWithinCooldown(t *testing.T) {
+	tracker := cooldown.New(time.Hour)
+	now := time.Now()
+
+	if remaining := tracker.Remaining("jdoe", now); remaining != 0 {
+		t.Fatalf("expected unchanged account to not wait, got %s", remaining)
+	}
+
+	tracker.RecordChange("jdoe", now)
+
+	if remaining := tracker.Remaining("JDoe", now.Add(10*time.Minute)); remaining != 50*time.Minute {
+		t.Errorf("expected 50m remaining, got %s", remaining)
+	}
+	if remaining := tracker.Remaining("other", now); remaining != 0 {
+		t.Errorf("expected other accounts to not wait, got %s", remaining)
+	}
+}
+
+func TestAllowsAfterCooldown(t *testing.T) {
+	tracker := cooldown.New(time.Hour)
+	now := time.Now()
+
+	tracker.RecordChange("jdoe", now.Add(-2*time.Hour))
+
+	if remaining := tracker.Remaining("jdoe", now); remaining != 0 {
+		t.Errorf("expected cooldown to be over, got %s", remaining)
+	}
+
+	if removed := tracker.CleanupExpired(); removed != 1 || tracker.Count() != 0 {
+		t.Errorf("expected expired change to be cleaned up, removed %d", removed)
+	}
+}
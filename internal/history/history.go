@@ -0,0 +1,114 @@
+package history
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The argon2id parameters follow the OWASP recommendation, so a leaked
+// memory dump doesn't make the remembered passwords cheap to crack.
+const (
+	argonTime    = 2
+	argonMemory  = 19 * 1024
+	argonThreads = 1
+	argonKeyLen  = 32
+	saltLen      = 16
+)
+
+type entry struct {
+	salt []byte
+	hash []byte
+}
+
+func (e entry) matches(password string) bool {
+	return subtle.ConstantTimeCompare(e.hash, hash(password, e.salt)) == 1
+}
+
+func hash(password string, salt []byte) []byte {
+	return argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+}
+
+// Tracker remembers salted hashes of the last passwords of every account, so
+// they can't be reused. It is kept in memory and forgotten on restart.
+type Tracker struct {
+	mu      sync.Mutex
+	count   uint
+	entries map[string][]entry
+}
+
+func New(count uint) *Tracker {
+	return &Tracker{
+		count:   count,
+		entries: make(map[string][]entry),
+	}
+}
+
+func key(account string) string {
+	return strings.ToLower(account)
+}
+
+// Contains reports whether password is one of the remembered passwords of the
+// account.
+func (t *Tracker) Contains(account, password string) bool {
+	t.mu.Lock()
+	entries := append([]entry(nil), t.entries[key(account)]...)
+	t.mu.Unlock()
+
+	// Every entry is compared, so the time taken doesn't tell which one
+	// matched.
+	found := false
+	for _, e := range entries {
+		if e.matches(password) {
+			found = true
+		}
+	}
+
+	return found
+}
+
+// RecordChange remembers the new password of the account. The old password is
+// remembered as well if nothing is known about the account yet, since it was
+// the most recent one.
+func (t *Tracker) RecordChange(account, oldPassword, newPassword string) error {
+	t.mu.Lock()
+	known := len(t.entries[key(account)]) > 0
+	t.mu.Unlock()
+
+	passwords := []string{newPassword}
+	if !known {
+		passwords = []string{oldPassword, newPassword}
+	}
+
+	added := make([]entry, 0, len(passwords))
+	for _, password := range passwords {
+		salt := make([]byte, saltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+
+		added = append(added, entry{salt: salt, hash: hash(password, salt)})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := append(t.entries[key(account)], added...)
+	if uint(len(entries)) > t.count {
+		entries = entries[uint(len(entries))-t.count:]
+	}
+	t.entries[key(account)] = entries
+
+	return nil
+}
+
+// Count returns the amount of accounts with remembered passwords.
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.entries)
+}
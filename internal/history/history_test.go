@@ -0,0 +1,44 @@
+package history_test
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/history"
+)
+
+func TestRejectsRecentPasswords(t *testing.T) {
+	tracker := history.New(3)
+
+	if err := tracker.RecordChange("jdoe", "Passw0rd!1", "Passw0rd!2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, password := range []string{"Passw0rd!1", "Passw0rd!2"} {
+		if !tracker.Contains("JDoe", password) {
+			t.Errorf("expected %q to be remembered", password)
+		}
+	}
+	if tracker.Contains("jdoe", "Passw0rd!3") {
+		t.Error("expected a fresh password to be accepted")
+	}
+	if tracker.Contains("other", "Passw0rd!1") {
+		t.Error("expected passwords of other accounts to be accepted")
+	}
+}
+
+func TestForgetsOldPasswords(t *testing.T) {
+	tracker := history.New(2)
+
+	_ = tracker.RecordChange("jdoe", "Passw0rd!1", "Passw0rd!2")
+	_ = tracker.RecordChange("jdoe", "Passw0rd!2", "Passw0rd!3")
+
+	if tracker.Contains("jdoe", "Passw0rd!1") {
+		t.Error("expected the oldest password to be forgotten")
+	}
+	if !tracker.Contains("jdoe", "Passw0rd!2") || !tracker.Contains("jdoe", "Passw0rd!3") {
+		t.Error("expected the last two passwords to be remembered")
+	}
+	if count := tracker.Count(); count != 1 {
+		t.Errorf("expected one account, got %d", count)
+	}
+}
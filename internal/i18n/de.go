@@ -0,0 +1,61 @@
+package i18n
+
+var german = map[string]string{
+	// Password policy
+	"the new password must be at most %d characters long":  "das neue Passwort darf höchstens %d Zeichen lang sein",
+	"the new password must be at least %d characters long": "das neue Passwort muss mindestens %d Zeichen lang sein",
+	"the new password must contain at least %d of the character classes numbers, symbols, uppercase and lowercase letters, it is missing %s": "das neue Passwort muss mindestens %d der Zeichenklassen Zahlen, Sonderzeichen, Groß- und Kleinbuchstaben enthalten, es fehlen %s",
+	"the new password must not include the username, not even reversed or with substituted characters":                                       "das neue Passwort darf den Benutzernamen nicht enthalten, auch nicht rückwärts oder mit ersetzten Zeichen",
+	"the new password must contain at least %d %s":                                          "das neue Passwort muss mindestens %d %s enthalten",
+	"the new password must not repeat the same character more than %d %s in a row":          "das neue Passwort darf dasselbe Zeichen nicht mehr als %d %s hintereinander wiederholen",
+	"the new password must not contain sequences like \"abc\" or \"987\" longer than %d %s": "das neue Passwort darf keine Folgen wie \"abc\" oder \"987\" enthalten, die länger als %d %s sind",
+	"the new password must not include the username":                                        "das neue Passwort darf den Benutzernamen nicht enthalten",
+	"the new password is too common, please choose something less predictable":              "das neue Passwort ist zu verbreitet, bitte wählen Sie ein weniger vorhersehbares",
+	"the new password has appeared in a data breach, please choose a different one":         "das neue Passwort ist in einem Datenleck aufgetaucht, bitte wählen Sie ein anderes",
+	"the new password is too easy to guess, please choose a longer or less predictable one": "das neue Passwort ist zu leicht zu erraten, bitte wählen Sie ein längeres oder weniger vorhersehbares",
+	"the new password must not match a recent password":                                     "das neue Passwort darf keinem kürzlich verwendeten Passwort entsprechen",
+	"the old password can't be same as the new one":                                         "das alte Passwort darf nicht mit dem neuen übereinstimmen",
+	"the new password must differ from the current one in at least %d %s":                   "das neue Passwort muss sich in mindestens %d %s vom aktuellen unterscheiden",
+	"the password must contain %s":                                                          "das Passwort muss %s enthalten",
+	"the password must contain %s and must not include the username":                        "das Passwort muss %s enthalten und darf den Benutzernamen nicht enthalten",
+	"at least %d %s": "mindestens %d %s",
+	"%d of the character classes numbers, symbols, uppercase and lowercase letters": "%d der Zeichenklassen Zahlen, Sonderzeichen, Groß- und Kleinbuchstaben",
+
+	// Password strength suggestions
+	"use a longer password":                                "verwenden Sie ein längeres Passwort",
+	"mix letters, numbers and symbols":                     "kombinieren Sie Buchstaben, Zahlen und Sonderzeichen",
+	"avoid common passwords and simple variations of them": "vermeiden Sie verbreitete Passwörter und einfache Abwandlungen davon",
+	"avoid using your username":                            "vermeiden Sie Ihren Benutzernamen",
+	"avoid repeated characters and sequences like \"abc\"": "vermeiden Sie wiederholte Zeichen und Folgen wie \"abc\"",
+
+	// Words used as arguments
+	"character":         "Zeichen",
+	"characters":        "Zeichen",
+	"number":            "Zahl",
+	"numbers":           "Zahlen",
+	"symbol":            "Sonderzeichen",
+	"symbols":           "Sonderzeichen",
+	"uppercase letter":  "Großbuchstaben",
+	"uppercase letters": "Großbuchstaben",
+	"lowercase letter":  "Kleinbuchstaben",
+	"lowercase letters": "Kleinbuchstaben",
+	"time":              "Mal",
+	"times":             "Mal",
+	"minute":            "Minute",
+	"minutes":           "Minuten",
+
+	// Password changes
+	"the username can't be empty":                                                        "der Benutzername darf nicht leer sein",
+	"the old password can't be empty":                                                    "das alte Passwort darf nicht leer sein",
+	"the new password can't be empty":                                                    "das neue Passwort darf nicht leer sein",
+	"the password of this account can't be changed":                                      "das Passwort dieses Kontos kann nicht geändert werden",
+	"the password could not be changed, please check your username and current password": "das Passwort konnte nicht geändert werden, bitte prüfen Sie Ihren Benutzernamen und Ihr aktuelles Passwort",
+	"too many failed attempts, try again later":                                          "zu viele fehlgeschlagene Versuche, bitte versuchen Sie es später erneut",
+	"the password was changed too recently":                                              "das Passwort wurde erst vor kurzem geändert",
+	"%s, try again in %d %s":                                                             "%s, bitte versuchen Sie es in %d %s erneut",
+	"too many requests, try again later":                                                 "zu viele Anfragen, bitte versuchen Sie es später erneut",
+	"password changed successfully":                                                      "Passwort erfolgreich geändert",
+	"method not found":                                                                   "Methode nicht gefunden",
+	"this feature is not enabled":                                                        "diese Funktion ist nicht aktiviert",
+	"password changes are temporarily unavailable due to maintenance, please try again later": "Passwortänderungen sind wegen Wartungsarbeiten vorübergehend nicht möglich, bitte versuchen Sie es später erneut",
+}
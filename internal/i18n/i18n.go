@@ -0,0 +1,167 @@
+// Package i18n translates user facing messages. Messages are written in
+// English, which also serves as the key into the catalogs of the other
+// languages.
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Locale string
+
+const (
+	English Locale = "en"
+	German  Locale = "de"
+)
+
+var catalogs = map[Locale]map[string]string{
+	German: german,
+}
+
+// Parse returns the locale matching a language tag like "de" or "de-DE",
+// unknown languages fall back to English.
+func Parse(tag string) Locale {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+
+	locale := Locale(base)
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+
+	return English
+}
+
+// FromAcceptLanguage returns the supported locale the client prefers most
+// according to an Accept-Language header.
+func FromAcceptLanguage(header string) Locale {
+	type candidate struct {
+		tag     string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+
+		candidates = append(candidates, candidate{tag, quality})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	for _, c := range candidates {
+		if c.quality <= 0 {
+			continue
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(c.tag), "-")
+		if Locale(base) == English {
+			return English
+		}
+		if _, ok := catalogs[Locale(base)]; ok {
+			return Locale(base)
+		}
+	}
+
+	return English
+}
+
+// Translate returns the translation of an English message, or the message
+// itself if there is none.
+func (l Locale) Translate(message string) string {
+	if translated, ok := catalogs[l][message]; ok {
+		return translated
+	}
+
+	return message
+}
+
+// Text is a message argument that is translated as well.
+type Text string
+
+// List is a message argument whose elements are translated and joined with
+// commas.
+type List []any
+
+func (l Locale) arg(a any) any {
+	switch v := a.(type) {
+	case Text:
+		return l.Translate(string(v))
+	case List:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(l.arg(item)))
+		}
+
+		return strings.Join(parts, ", ")
+	case error:
+		return Localize(v, l)
+	}
+
+	return a
+}
+
+// Sprintf translates format and formats it with args.
+func (l Locale) Sprintf(format string, args ...any) string {
+	translated := make([]any, 0, len(args))
+	for _, a := range args {
+		translated = append(translated, l.arg(a))
+	}
+
+	return fmt.Sprintf(l.Translate(format), translated...)
+}
+
+// Error is an error whose message is translated when it is shown. Its
+// Error method returns the English message.
+type Error struct {
+	format string
+	args   []any
+}
+
+// Errorf creates a translatable error. Errors passed as arguments are
+// translated as well, the first one can be found with errors.Is and
+// errors.As.
+func Errorf(format string, args ...any) *Error {
+	return &Error{format, args}
+}
+
+func (e *Error) Error() string {
+	return English.Sprintf(e.format, e.args...)
+}
+
+// Unwrap returns the first error argument.
+func (e *Error) Unwrap() error {
+	for _, a := range e.args {
+		if err, ok := a.(error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Error) Localize(l Locale) string {
+	return l.Sprintf(e.format, e.args...)
+}
+
+// Localize translates err if it is or wraps an Error, other errors are
+// returned as they are.
+func Localize(err error, l Locale) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Localize(l)
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,75 @@
+package i18n_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/i18n"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]i18n.Locale{
+		"de":    i18n.German,
+		"de-AT": i18n.German,
+		"DE":    i18n.German,
+		"en-US": i18n.English,
+		"fr":    i18n.English,
+		"":      i18n.English,
+	}
+
+	for tag, expected := range cases {
+		if actual := i18n.Parse(tag); actual != expected {
+			t.Errorf("expected %q to parse as %q, got %q", tag, expected, actual)
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	cases := map[string]i18n.Locale{
+		"de-DE,de;q=0.9,en;q=0.8": i18n.German,
+		"en-US,en;q=0.9,de;q=0.8": i18n.English,
+		"fr-FR,fr;q=0.9,de;q=0.5": i18n.German,
+		"en;q=0.2,de;q=0.7":       i18n.German,
+		"de;q=0,fr":               i18n.English,
+		"":                        i18n.English,
+	}
+
+	for header, expected := range cases {
+		if actual := i18n.FromAcceptLanguage(header); actual != expected {
+			t.Errorf("expected %q to resolve to %q, got %q", header, expected, actual)
+		}
+	}
+}
+
+func TestErrorLocalize(t *testing.T) {
+	inner := i18n.Errorf("the password was changed too recently")
+	err := i18n.Errorf("%s, try again in %d %s", inner, 5, i18n.Text("minutes"))
+
+	if expected := "the password was changed too recently, try again in 5 minutes"; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+	if expected := "das Passwort wurde erst vor kurzem geändert, bitte versuchen Sie es in 5 Minuten erneut"; err.Localize(i18n.German) != expected {
+		t.Errorf("expected %q, got %q", expected, err.Localize(i18n.German))
+	}
+	if !errors.Is(err, inner) {
+		t.Error("expected error to wrap its error argument")
+	}
+}
+
+func TestLocalizeList(t *testing.T) {
+	err := i18n.Errorf("the new password must contain at least %d of the character classes numbers, symbols, uppercase and lowercase letters, it is missing %s", 3, i18n.List{i18n.Text("numbers"), i18n.Text("symbols")})
+
+	expected := "das neue Passwort muss mindestens 3 der Zeichenklassen Zahlen, Sonderzeichen, Groß- und Kleinbuchstaben enthalten, es fehlen Zahlen, Sonderzeichen"
+	if actual := i18n.Localize(err, i18n.German); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestLocalizeUnknown(t *testing.T) {
+	if actual := i18n.Localize(errors.New("LDAP Result Code 49"), i18n.German); actual != "LDAP Result Code 49" {
+		t.Errorf("expected untranslatable errors to be kept, got %q", actual)
+	}
+	if actual := i18n.German.Translate("not in the catalog"); actual != "not in the catalog" {
+		t.Errorf("expected unknown messages to be kept, got %q", actual)
+	}
+}
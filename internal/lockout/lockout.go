@@ -0,0 +1,170 @@
+package lockout
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	failures    uint
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// Tracker counts consecutive failed attempts per account and locks the account
+// for a while once too many attempts failed. It is keyed on the account name
+// rather than the client IP, so distributing attempts over many IPs doesn't
+// help an attacker.
+type Tracker struct {
+	mu        sync.Mutex
+	threshold uint
+	duration  time.Duration
+	entries   map[string]*entry
+}
+
+func New(threshold uint, duration time.Duration) *Tracker {
+	return &Tracker{
+		threshold: threshold,
+		duration:  duration,
+		entries:   make(map[string]*entry),
+	}
+}
+
+func key(account string) string {
+	return strings.ToLower(account)
+}
+
+func (t *Tracker) IsLocked(account string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key(account)]
+
+	return ok && time.Now().Before(e.lockedUntil)
+}
+
+func (t *Tracker) RecordFailure(account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recordFailure(account, time.Now())
+}
+
+func (t *Tracker) recordFailure(account string, now time.Time) {
+	e, ok := t.entries[key(account)]
+	if !ok {
+		e = &entry{}
+		t.entries[key(account)] = e
+	}
+
+	// Failures before an expired lock don't count towards the next one.
+	if !e.lockedUntil.IsZero() && now.After(e.lockedUntil) {
+		*e = entry{}
+	}
+
+	e.failures++
+	e.lastFailure = now
+	if e.failures >= t.threshold {
+		e.lockedUntil = now.Add(t.duration)
+	}
+}
+
+// Attempt reports whether the account may try its credentials and, if so,
+// counts the attempt as failed right away. The check and the count happen
+// under one lock, so concurrent attempts can't exceed the threshold. Call
+// RecordSuccess when the credentials were valid and Forgive when the attempt
+// didn't get to check them.
+func (t *Tracker) Attempt(account string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	e, ok := t.entries[key(account)]
+	if ok && now.Before(e.lockedUntil) {
+		return false
+	}
+
+	t.recordFailure(account, now)
+
+	return true
+}
+
+// Forgive takes back an attempt that failed for another reason than wrong
+// credentials, e.g. because the directory was unreachable.
+func (t *Tracker) Forgive(account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key(account)]
+	if !ok || e.failures == 0 {
+		return
+	}
+
+	e.failures--
+	if e.failures < t.threshold {
+		e.lockedUntil = time.Time{}
+	}
+	if e.failures == 0 {
+		delete(t.entries, key(account))
+	}
+}
+
+func (t *Tracker) RecordSuccess(account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key(account))
+}
+
+// Count returns the amount of accounts that currently have failed attempts.
+func (t *Tracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.entries)
+}
+
+// CleanupExpired forgets accounts whose lock expired or whose last failure is
+// older than the lock duration, and returns how many were removed.
+func (t *Tracker) CleanupExpired() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for k, e := range t.entries {
+		if now.After(e.lockedUntil) && now.Sub(e.lastFailure) > t.duration {
+			delete(t.entries, k)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// StartCleanup periodically calls CleanupExpired until the returned function
+// is called.
+func (t *Tracker) StartCleanup(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				t.CleanupExpired()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
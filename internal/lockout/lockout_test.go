@@ -0,0 +1,100 @@
+package lockout_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/lockout"
+)
+
+func TestLocksAfterThreshold(t *testing.T) {
+	tracker := lockout.New(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		tracker.RecordFailure("jdoe")
+	}
+	if tracker.IsLocked("jdoe") {
+		t.Fatal("expected account to not be locked below the threshold")
+	}
+
+	tracker.RecordFailure("JDoe")
+	if !tracker.IsLocked("jdoe") {
+		t.Fatal("expected account to be locked at the threshold, ignoring case")
+	}
+	if tracker.IsLocked("other") {
+		t.Error("expected other accounts to be unaffected")
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	tracker := lockout.New(1, 20*time.Millisecond)
+
+	tracker.RecordFailure("jdoe")
+	if !tracker.IsLocked("jdoe") {
+		t.Fatal("expected account to be locked")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if tracker.IsLocked("jdoe") {
+		t.Error("expected lock to expire")
+	}
+	if removed := tracker.CleanupExpired(); removed != 1 || tracker.Count() != 0 {
+		t.Errorf("expected expired entry to be cleaned up, removed %d, %d left", removed, tracker.Count())
+	}
+}
+
+func TestSuccessResetsCounter(t *testing.T) {
+	tracker := lockout.New(2, time.Hour)
+
+	tracker.RecordFailure("jdoe")
+	tracker.RecordSuccess("jdoe")
+	tracker.RecordFailure("jdoe")
+
+	if tracker.IsLocked("jdoe") {
+		t.Error("expected success to reset the failure counter")
+	}
+}
+
+func TestAttemptIsAtomic(t *testing.T) {
+	tracker := lockout.New(3, time.Hour)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tracker.Attempt("jdoe") {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() != 3 {
+		t.Errorf("expected 3 concurrent attempts to be allowed, got %d", allowed.Load())
+	}
+}
+
+func TestForgiveTakesBackAttempt(t *testing.T) {
+	tracker := lockout.New(2, time.Hour)
+
+	tracker.Attempt("jdoe")
+	tracker.Attempt("jdoe")
+	if !tracker.IsLocked("jdoe") {
+		t.Fatal("expected account to be locked at the threshold")
+	}
+
+	tracker.Forgive("jdoe")
+	if tracker.IsLocked("jdoe") {
+		t.Error("expected a forgiven attempt to lift the lock")
+	}
+
+	tracker.Forgive("jdoe")
+	if tracker.Count() != 0 {
+		t.Errorf("expected no tracked accounts once all attempts are forgiven, got %d", tracker.Count())
+	}
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	RPCCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ldap_passwd_rpc_calls_total",
+		Help: "Amount of JSON-RPC calls by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ldap_passwd_rate_limited_requests_total",
+		Help: "Amount of requests denied by a rate limiter, by route.",
+	}, []string{"route"})
+)
+
+// NewServer serves all registered metrics in the Prometheus exposition format
+// at /metrics on their own address, so they aren't reachable through the
+// public listener of the app.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,51 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func scrape(t *testing.T, path string) (int, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	metrics.NewServer(":0").Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return rec.Code, string(body)
+}
+
+func TestNewServerServesCounters(t *testing.T) {
+	counter := metrics.RateLimited.WithLabelValues("/api/rpc")
+	before := testutil.ToFloat64(counter)
+
+	counter.Inc()
+
+	if actual := testutil.ToFloat64(counter) - before; actual != 1 {
+		t.Errorf("expected the counter to be increased by 1, got %v", actual)
+	}
+
+	status, body := scrape(t, "/metrics")
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+	if !strings.Contains(body, `ldap_passwd_rate_limited_requests_total{route="/api/rpc"}`) {
+		t.Errorf("expected the counter to be served, got %s", body)
+	}
+}
+
+func TestNewServerOnlyServesMetrics(t *testing.T) {
+	if status, _ := scrape(t, "/"); status != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
+	}
+}
@@ -1,95 +1,495 @@
 package options
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	ldapv3 "github.com/go-ldap/ldap/v3"
 	"github.com/joho/godotenv"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
 	ldap "github.com/netresearch/simple-ldap-go"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Opts struct {
 	LDAP             ldap.Config
 	ReadonlyUser     string
 	ReadonlyPassword string
+	MinTLSVersion    uint16
+	MetricsEnabled   bool
+	MetricsAddress   string
+	AuditLogPath     string
+	AdminToken       string
+	AdminTokenHash   string
+	WebhookURL       string
+	WebhookSecret    string
+	TrustedProxies   []netip.Prefix
+	LogLevel         slog.Level
+	LogFormat        string
+	RequireHTTPS     bool
+	CanonicalHost    string
+	AppName          string
+	LogoURL          string
+	PrimaryColor     string
+	CompressionLevel string
+	ExtraHeaders     map[string]string
+	BasePath         string
+	Check            bool
+
+	ChangePasswordEnabled      bool
+	PasswordExpiryEnabled      bool
+	MaintenanceMode            bool
+	RequireEnabledAccount      bool
+	EnumerationResistance      bool
+	EnumerationResistanceDelay time.Duration
+	LockoutThreshold           uint
+	LockoutDuration            time.Duration
+	MinPasswordAge             time.Duration
+	ChangeRateLimitRequests    uint
+	ChangeRateLimitWindow      time.Duration
+	RateLimitAlgorithm         string
+	RateLimitExemptCIDRs       []netip.Prefix
+	RequestTimeout             time.Duration
+	MaxBodyBytes               uint
+	LDAPTimeout                time.Duration
+	StatsInterval              time.Duration
+	StaticMaxAge               time.Duration
 
 	MinLength                  uint
+	MaxLength                  uint
 	MinNumbers                 uint
 	MinSymbols                 uint
 	MinUppercase               uint
 	MinLowercase               uint
+	MinCharacterClasses        uint
+	MaxRepeatedChars           uint
+	MaxSequentialChars         uint
+	MinStrengthScore           uint
+	MinChangedChars            uint
+	PasswordHistoryCount       uint
 	PasswordCanIncludeUsername bool
+	StrictUsernameCheck        bool
+	RejectCommonPasswords      bool
+	BreachedPasswords          *validators.BloomFilter
+	IncludePolicyInErrors      bool
+	ReportAllViolations        bool
+	AllowSamePassword          bool
+
+	// configFilePath and flags are kept to read the policy again with the
+	// same config file and flags in ReloadPolicy.
+	configFilePath string
+	flags          map[string]string
+}
+
+const (
+	RateLimitAlgorithmSliding = "sliding"
+	RateLimitAlgorithmBucket  = "bucket"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+const (
+	CompressionDisabled        = "disabled"
+	CompressionBestSpeed       = "bestspeed"
+	CompressionDefault         = "default"
+	CompressionBestCompression = "bestcompression"
+)
+
+// ConfigError lists all problems found in the configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
 }
 
-func panicWhenEmpty(name string, value *string) {
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Validate checks that the options are consistent with each other.
+func (o *Opts) Validate() error {
+	var problems []string
+
+	if o.MinCharacterClasses > 4 {
+		problems = append(problems, "The option --min-character-classes must be between 0 and 4")
+	}
+
+	if o.MinStrengthScore > 4 {
+		problems = append(problems, "The option --min-strength-score must be between 0 and 4")
+	}
+
+	if o.MaxLength > 0 && o.MaxLength < o.MinLength {
+		problems = append(problems, fmt.Sprintf("The option --max-length (%d) must not be less than --min-length (%d)", o.MaxLength, o.MinLength))
+	}
+
+	if u, err := url.Parse(o.LogoURL); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		problems = append(problems, "The option --logo-url must be a path or an http(s) URL")
+	}
+
+	if o.BasePath != "" && (!strings.HasPrefix(o.BasePath, "/") || strings.ContainsAny(o.BasePath, "?# ")) {
+		problems = append(problems, "The option --base-path must be a path like /pwreset")
+	}
+
+	if o.AllowSamePassword && o.MinChangedChars > 0 {
+		problems = append(problems, "The options --allow-same-password and --min-changed-chars can't be used together")
+	}
+
+	if o.RequireHTTPS && o.CanonicalHost == "" {
+		problems = append(problems, "The option --canonical-host is required when --require-https is set")
+	}
+	if u, err := url.Parse("https://" + o.CanonicalHost); o.CanonicalHost != "" && (err != nil || u.Host != o.CanonicalHost || u.User != nil) {
+		problems = append(problems, "The option --canonical-host must be a host like passwd.example.com, optionally with a port")
+	}
+
+	if o.PasswordExpiryEnabled && o.EnumerationResistance {
+		problems = append(problems, "The options --password-expiry-enabled and --enumeration-resistance can't be used together")
+	}
+
+	if o.MetricsEnabled && o.MetricsAddress == "" {
+		problems = append(problems, "The option --metrics-address must not be empty when --metrics is set")
+	}
+
+	if o.PrimaryColor != "" && !hexColor.MatchString(o.PrimaryColor) {
+		problems = append(problems, "The option --primary-color must be a hex color like #b8e9f4")
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+
+	return nil
+}
+
+// parser holds the state of a single parse of the options, so parsing doesn't
+// depend on package state and can run again, e.g. to reload the policy.
+type parser struct {
+	fs *flag.FlagSet
+	// configFile holds the values of the config file, keyed by the name of
+	// the environment variable they stand in for.
+	configFile map[string]string
+	// knownKeys collects the names of all environment variables that are read,
+	// so unknown keys in the config file can be reported.
+	knownKeys map[string]struct{}
+	// problems collects everything that is wrong with the configuration, so
+	// all of it can be reported at once.
+	problems []string
+}
+
+func newParser(name string) *parser {
+	return &parser{
+		fs:        flag.NewFlagSet(name, flag.ContinueOnError),
+		knownKeys: make(map[string]struct{}),
+	}
+}
+
+func (p *parser) requireNonEmpty(name string, value *string) {
 	if *value == "" {
-		log.Fatalf("err: The option --%s is required", name)
+		p.problems = append(p.problems, fmt.Sprintf("The option --%s is required", name))
 	}
 }
 
-func envStringOrDefault(name, d string) string {
+func (p *parser) envStringOrDefault(name, d string) string {
+	p.knownKeys[name] = struct{}{}
+
 	if v, exists := os.LookupEnv(name); exists && v != "" {
 		return v
 	}
 
+	if v, exists := p.configFile[name]; exists && v != "" {
+		return v
+	}
+
 	return d
 }
 
-func envIntOrDefault(name string, d uint64) uint {
-	raw := envStringOrDefault(name, fmt.Sprintf("%v", d))
+func (p *parser) envIntOrDefault(name string, d uint64) uint {
+	raw := p.envStringOrDefault(name, fmt.Sprintf("%v", d))
 
-	v, err := strconv.ParseUint(raw, 10, 8)
+	v, err := strconv.ParseUint(raw, 10, 32)
 	if err != nil {
-		log.Fatalf("err: could not parse environment variable \"%s\" (containing \"%s\") as uint: %v", name, raw, err)
+		p.problems = append(p.problems, fmt.Sprintf("could not parse environment variable \"%s\" (containing \"%s\") as uint: %v", name, raw, err))
+		return uint(d)
 	}
 
 	return uint(v)
 }
 
-func envBoolOrDefault(name string, d bool) bool {
-	raw := envStringOrDefault(name, fmt.Sprintf("%v", d))
+func (p *parser) envBoolOrDefault(name string, d bool) bool {
+	raw := p.envStringOrDefault(name, fmt.Sprintf("%v", d))
 
 	v2, err := strconv.ParseBool(raw)
 	if err != nil {
-		log.Fatalf("err: could not parse environment variable \"%s\" (containing \"%s\") as bool: %v", name, raw, err)
+		p.problems = append(p.problems, fmt.Sprintf("could not parse environment variable \"%s\" (containing \"%s\") as bool: %v", name, raw, err))
+		return d
 	}
 
 	return v2
 }
 
-func Parse() *Opts {
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func ParseTLSVersion(raw string) (uint16, error) {
+	v, ok := tlsVersions[raw]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version \"%s\", expected one of 1.0, 1.1, 1.2 or 1.3", raw)
+	}
+
+	return v, nil
+}
+
+// LogHandler returns a handler that writes log messages of at least the
+// configured level to w in the configured format.
+func (o *Opts) LogHandler(w io.Writer) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: o.LogLevel}
+
+	if o.LogFormat == LogFormatJSON {
+		return slog.NewJSONHandler(w, handlerOpts)
+	}
+
+	return slog.NewTextHandler(w, handlerOpts)
+}
+
+// ParseTrustedProxies parses a comma separated list of addresses and CIDR
+// ranges. Single addresses are treated as ranges containing only themselves.
+func ParseTrustedProxies(raw string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// ParseExtraHeaders parses a semicolon separated list of `Name:Value` pairs.
+// Only the first colon separates the name from the value, so values may
+// contain colons themselves.
+func ParseExtraHeaders(raw string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(raw, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("header %q is missing a value", strings.TrimSpace(entry))
+		}
+
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header %q has a value containing a line break", name)
+		}
+
+		headers[name] = value
+	}
+
+	return headers, nil
+}
+
+// TLSConfig returns the TLS configuration used for outbound connections.
+func (o *Opts) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: o.MinTLSVersion,
+	}
+}
+
+// Parse loads the .env files and reads the options from the command line
+// arguments, the environment and the config file. It exits after printing the
+// usage if asked for help.
+func Parse() (*Opts, error) {
 	if err := godotenv.Load(".env.local", ".env"); err != nil {
-		log.Printf("warn: could not load .env file: %s", err)
+		slog.Warn("could not load .env file", "err", err)
+	}
+
+	opts, err := ParseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+
+	return opts, err
+}
+
+// ParseArgs reads the options from args, the environment and the config file.
+// All problems with them are reported together in a ConfigError.
+func ParseArgs(args []string) (*Opts, error) {
+	p := newParser("ldap-passwd")
+
+	path := configPath(args)
+	if path != "" {
+		values, err := LoadConfigFile(path)
+		if err != nil {
+			p.problems = append(p.problems, fmt.Sprintf("could not load config file: %v", err))
+		}
+
+		p.configFile = values
 	}
 
 	var (
-		fLdapServer        = flag.String("ldap-server", envStringOrDefault("LDAP_SERVER", ""), "LDAP server URI, has to begin with `ldap://` or `ldaps://`. If this is an ActiveDirectory server, this *has* to be `ldaps://`.")
-		fIsActiveDirectory = flag.Bool("active-directory", envBoolOrDefault("LDAP_IS_AD", false), "Mark the LDAP server as ActiveDirectory.")
-		fBaseDN            = flag.String("base-dn", envStringOrDefault("LDAP_BASE_DN", ""), "Base DN of your LDAP directory.")
-		fReadonlyUser      = flag.String("readonly-user", envStringOrDefault("LDAP_READONLY_USER", ""), "User that can read all users in your LDAP directory.")
-		fReadonlyPassword  = flag.String("readonly-password", envStringOrDefault("LDAP_READONLY_PASSWORD", ""), "Password for the readonly user.")
-
-		fMinLength                  = flag.Uint("min-length", envIntOrDefault("MIN_LENGTH", 8), "Minimum length of the password.")
-		fMinNumbers                 = flag.Uint("min-numbers", envIntOrDefault("MIN_NUMBERS", 1), "Minimum amount of numbers in the password.")
-		fMinSymbols                 = flag.Uint("min-symbols", envIntOrDefault("MIN_SYMBOLS", 1), "Minimum amount of symbols in the password.")
-		fMinUppercase               = flag.Uint("min-uppercase", envIntOrDefault("MIN_UPPERCASE", 1), "Minimum amount of uppercase letters in the password.")
-		fMinLowercase               = flag.Uint("min-lowercase", envIntOrDefault("MIN_LOWERCASE", 1), "Minimum amount of lowercase letters in the password.")
-		fPasswordCanIncludeUsername = flag.Bool("password-can-include-username", envBoolOrDefault("PASSWORD_CAN_INCLUDE_USERNAME", false), "Enables that the password can include the password")
+		fConfigFile        = p.fs.String("config", p.envStringOrDefault("CONFIG_FILE", ""), "Path to a YAML config file that sets options by the names of their environment variables. Flags and environment variables take precedence over it.")
+		fLdapServer        = p.fs.String("ldap-server", p.envStringOrDefault("LDAP_SERVER", ""), "LDAP server URI, has to begin with `ldap://` or `ldaps://`. If this is an ActiveDirectory server, this *has* to be `ldaps://`. Separate multiple URIs with commas to fail over to the next server when one is unreachable.")
+		fIsActiveDirectory = p.fs.Bool("active-directory", p.envBoolOrDefault("LDAP_IS_AD", false), "Mark the LDAP server as ActiveDirectory.")
+		fBaseDN            = p.fs.String("base-dn", p.envStringOrDefault("LDAP_BASE_DN", ""), "Base DN of your LDAP directory.")
+		fReadonlyUser      = p.fs.String("readonly-user", p.envStringOrDefault("LDAP_READONLY_USER", ""), "User that can read all users in your LDAP directory.")
+		fReadonlyPassword  = p.fs.String("readonly-password", p.envStringOrDefault("LDAP_READONLY_PASSWORD", ""), "Password for the readonly user.")
+		fMetricsEnabled    = p.fs.Bool("metrics", p.envBoolOrDefault("METRICS_ENABLED", false), "Serve Prometheus metrics at /metrics on --metrics-address.")
+		fMetricsAddress    = p.fs.String("metrics-address", p.envStringOrDefault("METRICS_ADDRESS", ":9090"), "Address to serve the metrics on. It is separate from the app, so the metrics aren't reachable through the public listener.")
+		fAuditLogPath      = p.fs.String("audit-log", p.envStringOrDefault("AUDIT_LOG_PATH", ""), "Path of a file to append JSON audit events of all password changes to. Auditing is disabled when empty.")
+		fAdminToken        = p.fs.String("admin-token", p.envStringOrDefault("ADMIN_TOKEN", ""), "Shared secret that has to be sent in the X-Admin-Token header to access /admin/status. The endpoint is disabled when empty.")
+		fAdminTokenHash    = p.fs.String("admin-token-hash", p.envStringOrDefault("ADMIN_TOKEN_HASH", ""), "Bcrypt hash of the admin token, to avoid storing it in plain text. Takes precedence over --admin-token.")
+		fWebhookURL        = p.fs.String("webhook-url", p.envStringOrDefault("WEBHOOK_URL", ""), "URL to post a JSON event to whenever a password was changed. Webhooks are disabled when empty.")
+		fWebhookSecret     = p.fs.String("webhook-secret", p.envStringOrDefault("WEBHOOK_SECRET", ""), "Secret used to sign webhook requests with HMAC-SHA256 in the X-Signature-SHA256 header.")
+		fTrustedProxies    = p.fs.String("trusted-proxies", p.envStringOrDefault("TRUSTED_PROXIES", ""), "Comma separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted. These headers are ignored when empty.")
+		fLogLevel          = p.fs.String("log-level", p.envStringOrDefault("LOG_LEVEL", "info"), "Minimum level of log messages, one of `debug`, `info`, `warn` or `error`.")
+		fLogFormat         = p.fs.String("log-format", p.envStringOrDefault("LOG_FORMAT", LogFormatText), "Format of log messages, either `text` or `json`.")
+		fRequireHTTPS      = p.fs.Bool("require-https", p.envBoolOrDefault("REQUIRE_HTTPS", false), "Redirect plain HTTP requests to HTTPS and send a Strict-Transport-Security header. Behind a reverse proxy, the proxy has to be listed in the trusted proxies and set X-Forwarded-Proto.")
+		fCanonicalHost     = p.fs.String("canonical-host", p.envStringOrDefault("CANONICAL_HOST", ""), "Host, optionally with a port, that plain HTTP requests are redirected to with --require-https, e.g. `passwd.example.com`. The Host header of requests isn't used, since clients can set it to anything.")
+		fAppName           = p.fs.String("app-name", p.envStringOrDefault("APP_NAME", "LDAP Password Changer"), "Name of the application shown in the page title.")
+		fLogoURL           = p.fs.String("logo-url", p.envStringOrDefault("LOGO_URL", "/static/logo.webp"), "URL of the logo shown above the form, either a path on this server or an http(s) URL.")
+		fPrimaryColor      = p.fs.String("primary-color", p.envStringOrDefault("PRIMARY_COLOR", "#b8e9f4"), "Color of buttons, links, focus rings and the browser theme as a hex color like `#b8e9f4`.")
+		fCompressionLevel  = p.fs.String("compression-level", p.envStringOrDefault("COMPRESSION_LEVEL", CompressionBestSpeed), "Compression of responses, one of `disabled`, `bestspeed`, `default` or `bestcompression`.")
+		fExtraHeaders      = p.fs.String("extra-headers", p.envStringOrDefault("EXTRA_HEADERS", ""), "Semicolon separated `Name:Value` pairs of headers to set on every response, e.g. to satisfy security scanners.")
+		fBasePath          = p.fs.String("base-path", p.envStringOrDefault("BASE_PATH", ""), "Path prefix all routes are served under, e.g. `/pwreset` when a reverse proxy forwards only that path.")
+		fCheck             = p.fs.Bool("check", p.envBoolOrDefault("CHECK", false), "Check the configuration, the LDAP connection and the templates, print the outcome and exit without serving.")
+		fMinTLSVersion     = p.fs.String("min-tls-version", p.envStringOrDefault("MIN_TLS_VERSION", "1.2"), "Minimum TLS version for outbound connections, one of `1.0`, `1.1`, `1.2` or `1.3`.")
+
+		fChangePasswordEnabled      = p.fs.Bool("change-password-enabled", p.envBoolOrDefault("CHANGE_PASSWORD_ENABLED", true), "Offer changing the password. When disabled, the form is hidden and the change-password method is rejected.")
+		fPasswordExpiryEnabled      = p.fs.Bool("password-expiry-enabled", p.envBoolOrDefault("PASSWORD_EXPIRY_ENABLED", false), "Serve GET /api/password-expiry, which tells anyone how many days are left until the password of an account expires and thereby whether the account exists.")
+		fMaintenanceMode            = p.fs.Bool("maintenance-mode", p.envBoolOrDefault("MAINTENANCE_MODE", false), "Start in maintenance mode, which keeps the page up but rejects password changes. Admins can toggle it at runtime with POST /admin/maintenance.")
+		fPasswordHistoryCount       = p.fs.Uint("password-history-count", p.envIntOrDefault("PASSWORD_HISTORY_COUNT", 0), "Amount of previous passwords per account that can't be reused, 0 disables the check. The history is only kept in memory and starts empty after a restart.")
+		fRequireEnabledAccount      = p.fs.Bool("require-enabled-account", p.envBoolOrDefault("REQUIRE_ENABLED_ACCOUNT", false), "Only allow changing the password of accounts that are enabled.")
+		fEnumerationResistance      = p.fs.Bool("enumeration-resistance", p.envBoolOrDefault("ENUMERATION_RESISTANCE", false), "Report all failed password changes that aren't caused by the password policy with the same message, so they can't be used to find out which accounts exist.")
+		fEnumerationResistanceDelay = p.fs.Uint("enumeration-resistance-delay", p.envIntOrDefault("ENUMERATION_RESISTANCE_DELAY", 1000), "Minimum response time in milliseconds of failed password changes when enumeration resistance is enabled.")
+		fLockoutThreshold           = p.fs.Uint("lockout-threshold", p.envIntOrDefault("LOCKOUT_THRESHOLD", 0), "Amount of consecutive failed password changes after which an account is locked, 0 disables the lockout.")
+		fLockoutDurationMinutes     = p.fs.Uint("lockout-duration-minutes", p.envIntOrDefault("LOCKOUT_DURATION_MINUTES", 15), "Duration in minutes an account stays locked after too many failed password changes.")
+		fMinPasswordAgeMinutes      = p.fs.Uint("min-password-age-minutes", p.envIntOrDefault("MIN_PASSWORD_AGE_MINUTES", 0), "Minimum time in minutes between two successful password changes of the same account, 0 disables the check.")
+		fChangeRateLimitRequests    = p.fs.Uint("change-rate-limit-requests", p.envIntOrDefault("CHANGE_RATE_LIMIT_REQUESTS", 0), "Maximum amount of password change requests per client IP within the rate limit window, 0 disables the rate limit.")
+		fChangeRateLimitWindow      = p.fs.Uint("change-rate-limit-window-minutes", p.envIntOrDefault("CHANGE_RATE_LIMIT_WINDOW_MINUTES", 60), "Duration in minutes of the password change rate limit window.")
+		fRateLimitAlgorithm         = p.fs.String("rate-limit-algorithm", p.envStringOrDefault("RATE_LIMIT_ALGORITHM", RateLimitAlgorithmSliding), "Rate limiting algorithm, either `sliding` for a sliding window or `bucket` for a token bucket that allows short bursts.")
+		fRateLimitExemptCIDRs       = p.fs.String("rate-limit-exempt-cidrs", p.envStringOrDefault("RATE_LIMIT_EXEMPT_CIDRS", ""), "Comma separated addresses or CIDR ranges of clients that are never rate limited, e.g. monitoring or the help desk. The account lockout still applies to them.")
+		fRequestTimeoutSeconds      = p.fs.Uint("request-timeout-seconds", p.envIntOrDefault("REQUEST_TIMEOUT_SECONDS", 10), "Maximum time in seconds to read a request and to write its response.")
+		fMaxBodyBytes               = p.fs.Uint("max-body-bytes", p.envIntOrDefault("MAX_BODY_BYTES", 4*1024), "Maximum size in bytes of request bodies.")
+		fLDAPTimeoutSeconds         = p.fs.Uint("ldap-timeout-seconds", p.envIntOrDefault("LDAP_TIMEOUT_SECONDS", 10), "Maximum time in seconds to connect to an LDAP server before failing over to the next one. It only limits connecting, not how long a connected server takes to answer.")
+		fStatsIntervalMinutes       = p.fs.Uint("stats-interval-minutes", p.envIntOrDefault("STATS_INTERVAL_MINUTES", 0), "Interval in minutes at which the occupancy of the in-memory stores is logged, 0 disables the log line.")
+		fStaticMaxAgeSeconds        = p.fs.Uint("static-max-age-seconds", p.envIntOrDefault("STATIC_MAX_AGE_SECONDS", 24*60*60), "Time in seconds browsers may cache static assets like scripts, styles and icons, 0 disables caching headers.")
+		fBreachedPasswordsFilter    = p.fs.String("breached-passwords-filter", p.envStringOrDefault("BREACHED_PASSWORDS_FILTER", ""), "Path to a bloom filter of breached SHA-1 password hashes. Passwords that are probably in the filter are rejected; since bloom filters can produce false positives, an occasional safe password may be rejected as well.")
 	)
 
-	if !flag.Parsed() {
-		flag.Parse()
+	applyPolicy := p.policyFlags()
+
+	if err := p.fs.Parse(args); err != nil {
+		return nil, err
 	}
 
-	panicWhenEmpty("ldap-server", fLdapServer)
-	panicWhenEmpty("base-dn", fBaseDN)
-	panicWhenEmpty("readonly-user", fReadonlyUser)
-	panicWhenEmpty("readonly-password", fReadonlyPassword)
+	var configErr *ConfigError
+	if err := p.unknownConfigKeys(); errors.As(err, &configErr) {
+		for _, problem := range configErr.Problems {
+			p.problems = append(p.problems, fmt.Sprintf("%s: %s", *fConfigFile, problem))
+		}
+	}
+
+	p.requireNonEmpty("ldap-server", fLdapServer)
+	p.requireNonEmpty("base-dn", fBaseDN)
+	p.requireNonEmpty("readonly-user", fReadonlyUser)
+	p.requireNonEmpty("readonly-password", fReadonlyPassword)
+
+	if *fRateLimitAlgorithm != RateLimitAlgorithmSliding && *fRateLimitAlgorithm != RateLimitAlgorithmBucket {
+		p.problems = append(p.problems, fmt.Sprintf("The option --rate-limit-algorithm must be either \"%s\" or \"%s\"", RateLimitAlgorithmSliding, RateLimitAlgorithmBucket))
+	}
 
-	return &Opts{
+	minTLSVersion, err := ParseTLSVersion(*fMinTLSVersion)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("could not parse option --min-tls-version: %v", err))
+	}
+
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(*fLogLevel)); err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("could not parse option --log-level: %v", err))
+	}
+
+	if *fLogFormat != LogFormatText && *fLogFormat != LogFormatJSON {
+		p.problems = append(p.problems, fmt.Sprintf("The option --log-format must be either \"%s\" or \"%s\"", LogFormatText, LogFormatJSON))
+	}
+
+	switch *fCompressionLevel {
+	case CompressionDisabled, CompressionBestSpeed, CompressionDefault, CompressionBestCompression:
+	default:
+		p.problems = append(p.problems, fmt.Sprintf("The option --compression-level must be one of \"%s\", \"%s\", \"%s\" or \"%s\"", CompressionDisabled, CompressionBestSpeed, CompressionDefault, CompressionBestCompression))
+	}
+
+	trustedProxies, err := ParseTrustedProxies(*fTrustedProxies)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("could not parse option --trusted-proxies: %v", err))
+	}
+
+	if *fAdminTokenHash != "" {
+		if _, err := bcrypt.Cost([]byte(*fAdminTokenHash)); err != nil {
+			p.problems = append(p.problems, fmt.Sprintf("could not parse option --admin-token-hash as a bcrypt hash: %v", err))
+		}
+	}
+
+	rateLimitExemptCIDRs, err := ParseTrustedProxies(*fRateLimitExemptCIDRs)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("could not parse option --rate-limit-exempt-cidrs: %v", err))
+	}
+
+	extraHeaders, err := ParseExtraHeaders(*fExtraHeaders)
+	if err != nil {
+		p.problems = append(p.problems, fmt.Sprintf("could not parse option --extra-headers: %v", err))
+	}
+
+	var breachedPasswords *validators.BloomFilter
+	if *fBreachedPasswordsFilter != "" {
+		if breachedPasswords, err = validators.LoadBloomFilter(*fBreachedPasswordsFilter); err != nil {
+			p.problems = append(p.problems, fmt.Sprintf("could not load breached passwords filter \"%s\": %v", *fBreachedPasswordsFilter, err))
+		}
+	}
+
+	opts := &Opts{
 		LDAP: ldap.Config{
 			Server:            *fLdapServer,
 			BaseDN:            *fBaseDN,
@@ -97,12 +497,70 @@ func Parse() *Opts {
 		},
 		ReadonlyUser:     *fReadonlyUser,
 		ReadonlyPassword: *fReadonlyPassword,
+		MinTLSVersion:    minTLSVersion,
+		MetricsEnabled:   *fMetricsEnabled,
+		MetricsAddress:   *fMetricsAddress,
+		AuditLogPath:     *fAuditLogPath,
+		AdminToken:       *fAdminToken,
+		AdminTokenHash:   *fAdminTokenHash,
+		WebhookURL:       *fWebhookURL,
+		WebhookSecret:    *fWebhookSecret,
+		TrustedProxies:   trustedProxies,
+		LogLevel:         logLevel,
+		LogFormat:        *fLogFormat,
+		RequireHTTPS:     *fRequireHTTPS,
+		CanonicalHost:    *fCanonicalHost,
+		AppName:          *fAppName,
+		LogoURL:          *fLogoURL,
+		PrimaryColor:     *fPrimaryColor,
+		CompressionLevel: *fCompressionLevel,
+		ExtraHeaders:     extraHeaders,
+		BasePath:         strings.TrimRight(*fBasePath, "/"),
+		Check:            *fCheck,
+
+		ChangePasswordEnabled:      *fChangePasswordEnabled,
+		PasswordExpiryEnabled:      *fPasswordExpiryEnabled,
+		MaintenanceMode:            *fMaintenanceMode,
+		RequireEnabledAccount:      *fRequireEnabledAccount,
+		EnumerationResistance:      *fEnumerationResistance,
+		EnumerationResistanceDelay: time.Duration(*fEnumerationResistanceDelay) * time.Millisecond,
+		LockoutThreshold:           *fLockoutThreshold,
+		LockoutDuration:            time.Duration(*fLockoutDurationMinutes) * time.Minute,
+		MinPasswordAge:             time.Duration(*fMinPasswordAgeMinutes) * time.Minute,
+		ChangeRateLimitRequests:    *fChangeRateLimitRequests,
+		ChangeRateLimitWindow:      time.Duration(*fChangeRateLimitWindow) * time.Minute,
+		RateLimitAlgorithm:         *fRateLimitAlgorithm,
+		RateLimitExemptCIDRs:       rateLimitExemptCIDRs,
+		RequestTimeout:             time.Duration(*fRequestTimeoutSeconds) * time.Second,
+		MaxBodyBytes:               *fMaxBodyBytes,
+		LDAPTimeout:                time.Duration(*fLDAPTimeoutSeconds) * time.Second,
+		StatsInterval:              time.Duration(*fStatsIntervalMinutes) * time.Minute,
+		StaticMaxAge:               time.Duration(*fStaticMaxAgeSeconds) * time.Second,
 
-		MinLength:                  *fMinLength,
-		MinNumbers:                 *fMinNumbers,
-		MinSymbols:                 *fMinSymbols,
-		MinUppercase:               *fMinUppercase,
-		MinLowercase:               *fMinLowercase,
-		PasswordCanIncludeUsername: *fPasswordCanIncludeUsername,
+		PasswordHistoryCount: *fPasswordHistoryCount,
+		BreachedPasswords:    breachedPasswords,
+
+		configFilePath: path,
+		flags:          make(map[string]string),
+	}
+	p.fs.Visit(func(f *flag.Flag) {
+		opts.flags[f.Name] = f.Value.String()
+	})
+	applyPolicy(opts)
+	opts.LDAP.DialOptions = []ldapv3.DialOpt{
+		ldapv3.DialWithTLSConfig(opts.TLSConfig()),
+		// simple-ldap-go dials a new connection for every operation and
+		// doesn't expose it, so the timeout can only be applied to dialing.
+		ldapv3.DialWithDialer(&net.Dialer{Timeout: opts.LDAPTimeout}),
+	}
+
+	if err := opts.Validate(); errors.As(err, &configErr) {
+		p.problems = append(p.problems, configErr.Problems...)
 	}
+
+	if len(p.problems) > 0 {
+		return nil, &ConfigError{p.problems}
+	}
+
+	return opts, nil
 }
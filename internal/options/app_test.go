@@ -0,0 +1,193 @@
+package options_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+func TestTLSConfigMinVersion(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected uint16
+	}{
+		{Input: "1.0", Expected: tls.VersionTLS10},
+		{Input: "1.2", Expected: tls.VersionTLS12},
+		{Input: "1.3", Expected: tls.VersionTLS13},
+	}
+
+	for _, c := range cases {
+		v, err := options.ParseTLSVersion(c.Input)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", c.Input, err)
+		}
+
+		opts := &options.Opts{MinTLSVersion: v}
+		if actual := opts.TLSConfig().MinVersion; actual != c.Expected {
+			t.Errorf("expected MinVersion %x for %s, got %x", c.Expected, c.Input, actual)
+		}
+	}
+}
+
+func TestParseTLSVersionRejectsUnknown(t *testing.T) {
+	if _, err := options.ParseTLSVersion("1.4"); err == nil {
+		t.Error("expected an error for an unknown TLS version")
+	}
+}
+
+func TestValidateMaxLength(t *testing.T) {
+	if err := (&options.Opts{MinLength: 8, MaxLength: 8}).Validate(); err != nil {
+		t.Errorf("expected equal minimum and maximum length to be valid, got %v", err)
+	}
+	if err := (&options.Opts{MinLength: 8}).Validate(); err != nil {
+		t.Errorf("expected disabled maximum length to be valid, got %v", err)
+	}
+
+	err := (&options.Opts{MinLength: 12, MaxLength: 8}).Validate()
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 {
+		t.Fatalf("expected a config error with one problem, got %v", err)
+	}
+	if !strings.Contains(configErr.Problems[0], "--max-length") {
+		t.Errorf("expected problem to mention --max-length, got %q", configErr.Problems[0])
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := options.ParseTrustedProxies("10.0.0.0/8, 192.168.1.7,, 2001:db8::1/64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"10.0.0.0/8", "192.168.1.7/32", "2001:db8::/64"}
+	if len(prefixes) != len(expected) {
+		t.Fatalf("expected %d prefixes, got %v", len(expected), prefixes)
+	}
+	for i := range expected {
+		if prefixes[i].String() != expected[i] {
+			t.Errorf("expected prefix %s, got %s", expected[i], prefixes[i])
+		}
+	}
+
+	if _, err := options.ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected an error for an invalid range")
+	}
+	if _, err := options.ParseTrustedProxies("proxy.example.com"); err == nil {
+		t.Error("expected an error for a host name")
+	}
+}
+
+func TestParseExtraHeaders(t *testing.T) {
+	headers, err := options.ParseExtraHeaders("X-Permitted-Cross-Domain-Policies: none;; Content-Security-Policy:img-src https://cdn.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"X-Permitted-Cross-Domain-Policies": "none",
+		"Content-Security-Policy":           "img-src https://cdn.example.com",
+	}
+	if len(headers) != len(expected) {
+		t.Fatalf("expected %d headers, got %v", len(expected), headers)
+	}
+	for name, value := range expected {
+		if headers[name] != value {
+			t.Errorf("expected header %s to be %q, got %q", name, value, headers[name])
+		}
+	}
+
+	if _, err := options.ParseExtraHeaders("X-Frame-Options"); err == nil {
+		t.Error("expected an error for a header without a value")
+	}
+	if _, err := options.ParseExtraHeaders("X Frame: deny"); err == nil {
+		t.Error("expected an error for a header name with a space")
+	}
+	if _, err := options.ParseExtraHeaders(": deny"); err == nil {
+		t.Error("expected an error for an empty header name")
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	for _, format := range []string{options.LogFormatText, options.LogFormatJSON} {
+		for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+			var out bytes.Buffer
+			logger := slog.New((&options.Opts{LogLevel: level, LogFormat: format}).LogHandler(&out))
+
+			for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+				logger.Log(context.Background(), l, "message", "level", l.String())
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if expected := int(slog.LevelError-level)/4 + 1; len(lines) != expected {
+				t.Errorf("%s at %s: expected %d lines, got %q", format, level, expected, out.String())
+			}
+
+			isJSON := strings.HasPrefix(lines[0], "{")
+			if isJSON != (format == options.LogFormatJSON) {
+				t.Errorf("%s at %s: unexpected output %q", format, level, lines[0])
+			}
+		}
+	}
+}
+
+func TestValidateBranding(t *testing.T) {
+	valid := []*options.Opts{
+		{LogoURL: "/static/logo.webp", PrimaryColor: "#b8e9f4"},
+		{LogoURL: "https://cdn.example.com/logo.png", PrimaryColor: "#FFF"},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("expected %q and %q to be valid, got %v", opts.LogoURL, opts.PrimaryColor, err)
+		}
+	}
+
+	err := (&options.Opts{LogoURL: "javascript:alert(1)", PrimaryColor: "red;background:url(x)"}).Validate()
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Errorf("expected the logo URL and the color to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRejectsMinChangedCharsWithSamePassword(t *testing.T) {
+	opts := &options.Opts{MinLength: 8, MaxLength: 128, AllowSamePassword: true, MinChangedChars: 2, LogoURL: "/static/logo.webp"}
+
+	var configErr *options.ConfigError
+	if err := opts.Validate(); !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--min-changed-chars") {
+		t.Errorf("expected the combination to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRejectsPasswordExpiryWithEnumerationResistance(t *testing.T) {
+	opts := &options.Opts{MinLength: 8, MaxLength: 128, PasswordExpiryEnabled: true, EnumerationResistance: true, LogoURL: "/static/logo.webp"}
+
+	var configErr *options.ConfigError
+	if err := opts.Validate(); !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--password-expiry-enabled") {
+		t.Errorf("expected the combination to be rejected, got %v", err)
+	}
+}
+
+func TestValidateCanonicalHost(t *testing.T) {
+	for _, host := range []string{"passwd.example.com", "passwd.example.com:8443", "[2001:db8::1]:8443"} {
+		opts := &options.Opts{MinLength: 8, MaxLength: 128, LogoURL: "/static/logo.webp", RequireHTTPS: true, CanonicalHost: host}
+		if err := opts.Validate(); err != nil {
+			t.Errorf("expected %q to be valid, got %v", host, err)
+		}
+	}
+
+	for _, host := range []string{"", "https://passwd.example.com", "passwd.example.com/path", "user@passwd.example.com", "evil.example.com?"} {
+		opts := &options.Opts{MinLength: 8, MaxLength: 128, LogoURL: "/static/logo.webp", RequireHTTPS: true, CanonicalHost: host}
+
+		var configErr *options.ConfigError
+		if err := opts.Validate(); !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--canonical-host") {
+			t.Errorf("expected %q to be rejected, got %v", host, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package options
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile reads a YAML file that maps the names of environment
+// variables, in any case, to their values. Values in the environment take
+// precedence over the file.
+func LoadConfigFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed map[string]any
+	if err := yaml.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse config file \"%s\": %w", path, err)
+	}
+
+	values := make(map[string]string, len(parsed))
+	var problems []string
+	for key, value := range parsed {
+		switch v := value.(type) {
+		case nil:
+			values[strings.ToUpper(key)] = ""
+		case string, bool, int, float64:
+			values[strings.ToUpper(key)] = fmt.Sprint(v)
+		default:
+			problems = append(problems, fmt.Sprintf("The key \"%s\" in the config file must have a single value", key))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, &ConfigError{problems}
+	}
+
+	return values, nil
+}
+
+// unknownConfigKeys returns a ConfigError for keys of the config file that
+// don't belong to any option.
+func (p *parser) unknownConfigKeys() error {
+	var problems []string
+	for key := range p.configFile {
+		if _, ok := p.knownKeys[key]; !ok {
+			problems = append(problems, fmt.Sprintf("Unknown key \"%s\" in the config file", key))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ConfigError{problems}
+	}
+
+	return nil
+}
+
+// configPath returns the path of the config file, which has to be known before
+// the other flags are defined, since their defaults depend on it.
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return os.Getenv("CONFIG_FILE")
+}
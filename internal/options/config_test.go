@@ -0,0 +1,312 @@
+package options_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+const sampleConfig = `
+ldap_server: ldaps://dc1.example.com:636
+LDAP_BASE_DN: DC=example,DC=com
+LDAP_READONLY_USER: readonly
+LDAP_READONLY_PASSWORD: readonly
+LDAP_IS_AD: true
+MIN_LENGTH: 10
+MIN_NUMBERS: 3
+MIN_SYMBOLS: 4
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	return path
+}
+
+func parse(t *testing.T, args ...string) (*options.Opts, error) {
+	t.Helper()
+
+	return options.ParseArgs(args)
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	values, err := options.LoadConfigFile(writeConfig(t, sampleConfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"LDAP_SERVER": "ldaps://dc1.example.com:636",
+		"LDAP_IS_AD":  "true",
+		"MIN_LENGTH":  "10",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestLoadConfigFileRejectsNestedValues(t *testing.T) {
+	_, err := options.LoadConfigFile(writeConfig(t, "LDAP:\n  SERVER: ldaps://dc1.example.com:636\n"))
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("expected a config error, got %v", err)
+	}
+}
+
+func TestParseConfigFilePrecedence(t *testing.T) {
+	path := writeConfig(t, sampleConfig)
+	t.Setenv("MIN_NUMBERS", "5")
+	t.Setenv("MIN_SYMBOLS", "5")
+
+	opts, err := parse(t, "--config", path, "--min-symbols", "6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.LDAP.Server != "ldaps://dc1.example.com:636" || !opts.LDAP.IsActiveDirectory {
+		t.Errorf("expected LDAP options from the config file, got %+v", opts.LDAP)
+	}
+	if opts.MinLength != 10 {
+		t.Errorf("expected file to override the default, got %d", opts.MinLength)
+	}
+	if opts.MinNumbers != 5 {
+		t.Errorf("expected environment to override the file, got %d", opts.MinNumbers)
+	}
+	if opts.MinSymbols != 6 {
+		t.Errorf("expected flag to override the environment, got %d", opts.MinSymbols)
+	}
+	if opts.MinUppercase != 1 {
+		t.Errorf("expected default when nothing is set, got %d", opts.MinUppercase)
+	}
+}
+
+func TestParseRejectsUnknownConfigKeys(t *testing.T) {
+	_, err := parse(t, "--config", writeConfig(t, sampleConfig+"MIN_LENGHT: 12\n"))
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "MIN_LENGHT") {
+		t.Errorf("expected unknown key to be reported, got %v", err)
+	}
+}
+
+func TestParseReportsAllProblems(t *testing.T) {
+	t.Setenv("MIN_NUMBERS", "many")
+	t.Setenv("LDAP_IS_AD", "maybe")
+
+	_, err := parse(t, "--ldap-server", "ldaps://dc1.example.com:636", "--min-length", "12", "--max-length", "8")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+
+	expected := []string{
+		"could not parse environment variable \"LDAP_IS_AD\"",
+		"could not parse environment variable \"MIN_NUMBERS\"",
+		"The option --base-dn is required",
+		"The option --readonly-user is required",
+		"The option --readonly-password is required",
+		"The option --max-length (8) must not be less than --min-length (12)",
+	}
+	if len(configErr.Problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %q", len(expected), configErr.Problems)
+	}
+	for i, problem := range expected {
+		if !strings.HasPrefix(configErr.Problems[i], problem) {
+			t.Errorf("expected problem %d to start with %q, got %q", i, problem, configErr.Problems[i])
+		}
+	}
+}
+
+func TestParseArgsIsReentrant(t *testing.T) {
+	path := writeConfig(t, sampleConfig)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(minLength uint) {
+			defer wg.Done()
+
+			opts, err := options.ParseArgs([]string{"--config", path, "--min-length", fmt.Sprint(minLength)})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if opts.MinLength != minLength {
+				t.Errorf("expected min length %d, got %d", minLength, opts.MinLength)
+			}
+		}(uint(12 + i))
+	}
+	wg.Wait()
+
+	// A problem of an earlier parse must not leak into the next one.
+	if _, err := parse(t, "--config", path, "--log-format", "xml"); err == nil {
+		t.Fatal("expected the log format to be rejected")
+	}
+	if _, err := parse(t, "--config", path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseRejectsInvalidLogOptions(t *testing.T) {
+	_, err := parse(t, "--config", writeConfig(t, sampleConfig), "--log-level", "verbose", "--log-format", "xml")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 2 {
+		t.Fatalf("expected two problems, got %v", err)
+	}
+	if !strings.Contains(configErr.Problems[0], "--log-level") || !strings.Contains(configErr.Problems[1], "--log-format") {
+		t.Errorf("expected problems with the log options, got %q", configErr.Problems)
+	}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	opts, err := parse(t, "--config", writeConfig(t, sampleConfig), "--request-timeout-seconds", "30", "--ldap-timeout-seconds", "5", "--max-body-bytes", "8192")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if opts.RequestTimeout != 30*time.Second || opts.LDAPTimeout != 5*time.Second || opts.MaxBodyBytes != 8192 {
+		t.Errorf("expected the configured limits, got %s, %s and %d", opts.RequestTimeout, opts.LDAPTimeout, opts.MaxBodyBytes)
+	}
+	if len(opts.LDAP.DialOptions) != 2 {
+		t.Errorf("expected the TLS config and the dialer with the timeout, got %d dial options", len(opts.LDAP.DialOptions))
+	}
+}
+
+func TestParseRejectsUnknownCompressionLevel(t *testing.T) {
+	_, err := parse(t, "--config", writeConfig(t, sampleConfig), "--compression-level", "fastest")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--compression-level") {
+		t.Errorf("expected the compression level to be rejected, got %v", err)
+	}
+}
+
+func TestParseRejectsInvalidExtraHeaders(t *testing.T) {
+	t.Setenv("EXTRA_HEADERS", "X-Frame-Options")
+
+	_, err := parse(t, "--config", writeConfig(t, sampleConfig))
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--extra-headers") {
+		t.Errorf("expected the extra headers to be rejected, got %v", err)
+	}
+}
+
+func TestParseRateLimitExemptCIDRs(t *testing.T) {
+	opts, err := parse(t, "--config", writeConfig(t, sampleConfig), "--rate-limit-exempt-cidrs", "10.1.0.0/16, 192.168.5.10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.RateLimitExemptCIDRs) != 2 {
+		t.Errorf("expected two exempt ranges, got %v", opts.RateLimitExemptCIDRs)
+	}
+
+	_, err = parse(t, "--config", writeConfig(t, sampleConfig), "--rate-limit-exempt-cidrs", "helpdesk.example.com")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--rate-limit-exempt-cidrs") {
+		t.Errorf("expected the exempt ranges to be rejected, got %v", err)
+	}
+}
+
+func TestParseRejectsInvalidAdminTokenHash(t *testing.T) {
+	_, err := parse(t, "--config", writeConfig(t, sampleConfig), "--admin-token-hash", "s3cret")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--admin-token-hash") {
+		t.Errorf("expected the admin token hash to be rejected, got %v", err)
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	opts, err := parse(t, "--config", writeConfig(t, sampleConfig), "--base-path", "/pwreset/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.BasePath != "/pwreset" {
+		t.Errorf("expected the trailing slash to be removed, got %q", opts.BasePath)
+	}
+
+	_, err = parse(t, "--config", writeConfig(t, sampleConfig), "--base-path", "pwreset")
+
+	var configErr *options.ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "--base-path") {
+		t.Errorf("expected a base path without a leading slash to be rejected, got %v", err)
+	}
+}
+
+func TestReloadPolicy(t *testing.T) {
+	t.Setenv("MIN_NUMBERS", "5")
+	path := writeConfig(t, sampleConfig)
+
+	opts, err := parse(t, "--config", path, "--min-symbols", "6")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changed := strings.Replace(sampleConfig, "MIN_LENGTH: 10", "MIN_LENGTH: 14", 1) + "MAX_REPEATED_CHARS: 3\n"
+	if err := os.WriteFile(path, []byte(changed), 0o600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	reloaded, err := opts.ReloadPolicy()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if reloaded.MinLength != 14 || reloaded.MaxRepeatedChars != 3 {
+		t.Errorf("expected the policy from the config file, got %d and %d", reloaded.MinLength, reloaded.MaxRepeatedChars)
+	}
+	if reloaded.MinNumbers != 5 || reloaded.MinSymbols != 6 {
+		t.Errorf("expected the environment and the flag to keep precedence, got %d and %d", reloaded.MinNumbers, reloaded.MinSymbols)
+	}
+	if opts.MinLength != 10 || reloaded.LDAP.Server != opts.LDAP.Server {
+		t.Errorf("expected only a copy with a new policy, got %+v", reloaded)
+	}
+}
+
+func TestReloadPolicyReportsProblems(t *testing.T) {
+	path := writeConfig(t, sampleConfig)
+
+	opts, err := parse(t, "--config", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(sampleConfig+"MAX_REPEATED_CHARS: many\n"), 0o600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	var configErr *options.ConfigError
+	if _, err := opts.ReloadPolicy(); !errors.As(err, &configErr) || len(configErr.Problems) != 1 || !strings.Contains(configErr.Problems[0], "MAX_REPEATED_CHARS") {
+		t.Fatalf("expected the invalid value to be reported, got %v", err)
+	}
+
+	// Neither the failed reload nor a later parse may see the other's problems.
+	if _, err := parse(t, "--config", writeConfig(t, sampleConfig)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(sampleConfig), 0o600); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+	if _, err := opts.ReloadPolicy(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package options
+
+import "fmt"
+
+// policyFlags defines the options of the password policy and returns a
+// function that copies their values into opts. They are kept apart from the
+// other options, so the policy can be reloaded at runtime.
+func (p *parser) policyFlags() func(opts *Opts) {
+	var (
+		fMinLength                  = p.fs.Uint("min-length", p.envIntOrDefault("MIN_LENGTH", 8), "Minimum length of the password.")
+		fMaxLength                  = p.fs.Uint("max-length", p.envIntOrDefault("MAX_LENGTH", 128), "Maximum length of the password, 0 disables the limit.")
+		fMinNumbers                 = p.fs.Uint("min-numbers", p.envIntOrDefault("MIN_NUMBERS", 1), "Minimum amount of numbers in the password.")
+		fMinSymbols                 = p.fs.Uint("min-symbols", p.envIntOrDefault("MIN_SYMBOLS", 1), "Minimum amount of symbols in the password.")
+		fMinUppercase               = p.fs.Uint("min-uppercase", p.envIntOrDefault("MIN_UPPERCASE", 1), "Minimum amount of uppercase letters in the password.")
+		fMinLowercase               = p.fs.Uint("min-lowercase", p.envIntOrDefault("MIN_LOWERCASE", 1), "Minimum amount of lowercase letters in the password.")
+		fMinCharacterClasses        = p.fs.Uint("min-character-classes", p.envIntOrDefault("MIN_CHARACTER_CLASSES", 0), "Minimum amount of character classes (numbers, symbols, uppercase and lowercase letters) in the password. When set, this replaces the individual minimums.")
+		fMaxRepeatedChars           = p.fs.Uint("max-repeated-chars", p.envIntOrDefault("MAX_REPEATED_CHARS", 0), "Maximum amount of identical characters in a row in the password, 0 disables the check.")
+		fMaxSequentialChars         = p.fs.Uint("max-sequential-chars", p.envIntOrDefault("MAX_SEQUENTIAL_CHARS", 0), "Maximum length of letter or number sequences like `abc` or `987` in the password, 0 disables the check.")
+		fMinStrengthScore           = p.fs.Uint("min-strength-score", p.envIntOrDefault("MIN_STRENGTH_SCORE", 0), "Minimum estimated strength of the password from 0 (too guessable) to 4 (very unguessable), 0 disables the check.")
+		fMinChangedChars            = p.fs.Uint("min-changed-chars", p.envIntOrDefault("MIN_CHANGED_CHARS", 0), "Minimum amount of characters that have to be inserted, deleted or replaced to get from the current to the new password, 0 disables the check.")
+		fPasswordCanIncludeUsername = p.fs.Bool("password-can-include-username", p.envBoolOrDefault("PASSWORD_CAN_INCLUDE_USERNAME", false), "Enables that the password can include the password")
+		fStrictUsernameCheck        = p.fs.Bool("strict-username-check", p.envBoolOrDefault("STRICT_USERNAME_CHECK", false), "Also reject passwords that contain the username with common character substitutions like Adm1n for admin, or reversed.")
+		fIncludePolicyInErrors      = p.fs.Bool("include-policy-in-errors", p.envBoolOrDefault("INCLUDE_POLICY_IN_ERRORS", false), "Append the complete password policy to password validation errors.")
+		fReportAllViolations        = p.fs.Bool("report-all-violations", p.envBoolOrDefault("REPORT_ALL_VIOLATIONS", false), "Report every password policy violation when changing the password, instead of only the first one.")
+		fAllowSamePassword          = p.fs.Bool("allow-same-password", p.envBoolOrDefault("ALLOW_SAME_PASSWORD", false), "Allows setting the new password to the current one, e.g. to reset its expiry.")
+		fRejectCommonPasswords      = p.fs.Bool("reject-common-passwords", p.envBoolOrDefault("REJECT_COMMON_PASSWORDS", false), "Rejects passwords that are on a built-in list of the most common passwords.")
+	)
+
+	return func(opts *Opts) {
+		opts.MinLength = *fMinLength
+		opts.MaxLength = *fMaxLength
+		opts.MinNumbers = *fMinNumbers
+		opts.MinSymbols = *fMinSymbols
+		opts.MinUppercase = *fMinUppercase
+		opts.MinLowercase = *fMinLowercase
+		opts.MinCharacterClasses = *fMinCharacterClasses
+		opts.MaxRepeatedChars = *fMaxRepeatedChars
+		opts.MaxSequentialChars = *fMaxSequentialChars
+		opts.MinStrengthScore = *fMinStrengthScore
+		opts.MinChangedChars = *fMinChangedChars
+		opts.PasswordCanIncludeUsername = *fPasswordCanIncludeUsername
+		opts.StrictUsernameCheck = *fStrictUsernameCheck
+		opts.IncludePolicyInErrors = *fIncludePolicyInErrors
+		opts.ReportAllViolations = *fReportAllViolations
+		opts.AllowSamePassword = *fAllowSamePassword
+		opts.RejectCommonPasswords = *fRejectCommonPasswords
+	}
+}
+
+// ReloadPolicy reads the password policy again from the config file and
+// returns a copy of o with it. Only the config file can change while the
+// process runs: the environment of a process is fixed once it started, so
+// policy options set there or as flags keep their value and still take
+// precedence over the file. Problems are reported in a ConfigError.
+func (o *Opts) ReloadPolicy() (*Opts, error) {
+	p := newParser("reload")
+	if o.configFilePath != "" {
+		values, err := LoadConfigFile(o.configFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("could not load config file: %w", err)
+		}
+
+		p.configFile = values
+	}
+
+	applyPolicy := p.policyFlags()
+	for name, value := range o.flags {
+		if p.fs.Lookup(name) != nil {
+			_ = p.fs.Set(name, value)
+		}
+	}
+
+	if len(p.problems) > 0 {
+		return nil, &ConfigError{p.problems}
+	}
+
+	reloaded := *o
+	applyPolicy(&reloaded)
+
+	if err := reloaded.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &reloaded, nil
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets is a set of token buckets, one per key. Every bucket starts full,
+// allows a burst of up to its capacity and then refills steadily, so that
+// the full capacity is available again after one window.
+type Buckets struct {
+	mu        sync.Mutex
+	capacity  float64
+	perToken  time.Duration
+	window    time.Duration
+	entries   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewBuckets(capacity int, window time.Duration) *Buckets {
+	return &Buckets{
+		capacity: float64(capacity),
+		perToken: window / time.Duration(capacity),
+		window:   window,
+		entries:  make(map[string]*bucket),
+	}
+}
+
+// Take removes a token from the bucket of key. If the bucket is empty, it
+// returns false and how long it takes until the next token is available.
+func (b *Buckets) Take(key string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+
+	e, ok := b.entries[key]
+	if !ok {
+		e = &bucket{tokens: b.capacity, last: now}
+		b.entries[key] = e
+	}
+
+	e.tokens = math.Min(b.capacity, e.tokens+float64(now.Sub(e.last))/float64(b.perToken))
+	e.last = now
+
+	if e.tokens < 1 {
+		return false, time.Duration((1 - e.tokens) * float64(b.perToken))
+	}
+
+	e.tokens--
+
+	return true, 0
+}
+
+// Count returns the amount of keys that currently have a bucket.
+func (b *Buckets) Count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.entries)
+}
+
+// sweep forgets buckets that were refilled completely, since they behave
+// exactly like new ones. It runs at most once per window.
+func (b *Buckets) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.window {
+		return
+	}
+
+	for k, e := range b.entries {
+		if now.Sub(e.last) >= b.window {
+			delete(b.entries, k)
+		}
+	}
+	b.lastSweep = now
+}
+
+// TokenBucket is a limiter.LimiterHandler that allows bursts of up to
+// Config.Max requests and refills at Config.Max requests per
+// Config.Expiration. Its state is kept in memory, Config.Storage is ignored.
+type TokenBucket struct{}
+
+func (TokenBucket) New(cfg limiter.Config) fiber.Handler {
+	buckets := NewBuckets(cfg.Max, cfg.Expiration)
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		ok, retryAfter := buckets.Take(cfg.KeyGenerator(c), time.Now())
+		if !ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+			return cfg.LimitReached(c)
+		}
+
+		return c.Next()
+	}
+}
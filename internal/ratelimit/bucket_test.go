@@ -0,0 +1,92 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/ratelimit"
+)
+
+func TestBucketsAllowBurst(t *testing.T) {
+	buckets := ratelimit.NewBuckets(3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := buckets.Take("client", now); !ok {
+			t.Fatalf("expected request %d of the burst to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := buckets.Take("client", now)
+	if ok {
+		t.Fatal("expected request after the burst to be denied")
+	}
+	if retryAfter != 20*time.Second {
+		t.Errorf("expected retry after 20s, got %s", retryAfter)
+	}
+
+	if ok, _ := buckets.Take("other", now); !ok {
+		t.Error("expected other keys to have their own bucket")
+	}
+}
+
+func TestBucketsRefillSteadily(t *testing.T) {
+	buckets := ratelimit.NewBuckets(3, time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		buckets.Take("client", now)
+	}
+
+	// One token is refilled every 20 seconds.
+	for i := 1; i <= 5; i++ {
+		now = now.Add(20 * time.Second)
+
+		if ok, _ := buckets.Take("client", now); !ok {
+			t.Fatalf("expected request %d to be allowed after refill", i)
+		}
+		if ok, _ := buckets.Take("client", now); ok {
+			t.Fatalf("expected only one token to be refilled before request %d", i)
+		}
+	}
+}
+
+func TestBucketsForgetFullBuckets(t *testing.T) {
+	buckets := ratelimit.NewBuckets(3, time.Minute)
+	now := time.Now()
+
+	buckets.Take("client", now)
+	buckets.Take("other", now.Add(2*time.Minute))
+
+	if buckets.Count() != 1 {
+		t.Errorf("expected refilled bucket to be forgotten, got %d buckets", buckets.Count())
+	}
+}
+
+func TestTokenBucketMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", limiter.New(limiter.Config{
+		Max:               1,
+		Expiration:        time.Minute,
+		LimiterMiddleware: ratelimit.TokenBucket{},
+	}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for i, expected := range []int{fiber.StatusOK, fiber.StatusTooManyRequests} {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.StatusCode != expected {
+			t.Fatalf("expected status %d for request %d, got %d", expected, i+1, res.StatusCode)
+		}
+		if expected == fiber.StatusTooManyRequests && res.Header.Get(fiber.HeaderRetryAfter) != "60" {
+			t.Errorf("expected Retry-After of 60, got %q", res.Header.Get(fiber.HeaderRetryAfter))
+		}
+	}
+}
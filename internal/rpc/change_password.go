@@ -1,12 +1,42 @@
 package rpc
 
 import (
-	"fmt"
+	"errors"
+	"math"
 	"strings"
+	"time"
 
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/audit"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/i18n"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/strength"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/webhook"
+	ldap "github.com/netresearch/simple-ldap-go"
 )
 
+var (
+	ErrAccountNotChangeable error = i18n.Errorf("the password of this account can't be changed")
+	ErrPasswordNotChanged   error = i18n.Errorf("the password could not be changed, please check your username and current password")
+	ErrTooManyAttempts      error = i18n.Errorf("too many failed attempts, try again later")
+	ErrPasswordTooYoung     error = i18n.Errorf("the password was changed too recently")
+	ErrEmptyUsername        error = i18n.Errorf("the username can't be empty")
+	ErrEmptyOldPassword     error = i18n.Errorf("the old password can't be empty")
+	ErrEmptyNewPassword     error = i18n.Errorf("the new password can't be empty")
+	ErrRecentPassword       error = i18n.Errorf("the new password must not match a recent password")
+)
+
+// policyError marks errors caused by the new password not satisfying the
+// password policy. These are safe to show even when hiding account details.
+type policyError struct {
+	error
+}
+
+func (e policyError) Unwrap() error {
+	return e.error
+}
+
 func pluralize(word string, amount uint) string {
 	if amount == 1 {
 		return word
@@ -15,7 +45,244 @@ func pluralize(word string, amount uint) string {
 	return word + "s"
 }
 
-func (c *Handler) changePassword(params []string) ([]string, error) {
+func missingCharacterClasses(password string) i18n.List {
+	missing := make(i18n.List, 0, 4)
+
+	if !validators.MinNumbersInString(password, 1) {
+		missing = append(missing, i18n.Text("numbers"))
+	}
+	if !validators.MinSymbolsInString(password, 1) {
+		missing = append(missing, i18n.Text("symbols"))
+	}
+	if !validators.MinUppercaseLettersInString(password, 1) {
+		missing = append(missing, i18n.Text("uppercase letters"))
+	}
+	if !validators.MinLowercaseLettersInString(password, 1) {
+		missing = append(missing, i18n.Text("lowercase letters"))
+	}
+
+	return missing
+}
+
+// PolicySummary describes the complete password policy configured in opts.
+func PolicySummary(opts *options.Opts) string {
+	return policySummary(opts).Error()
+}
+
+func policySummary(opts *options.Opts) *i18n.Error {
+	requirements := i18n.List{i18n.Errorf("at least %d %s", opts.MinLength, i18n.Text(pluralize("character", opts.MinLength)))}
+
+	if opts.MinCharacterClasses > 0 {
+		requirements = append(requirements, i18n.Errorf("%d of the character classes numbers, symbols, uppercase and lowercase letters", opts.MinCharacterClasses))
+	} else {
+		if opts.MinNumbers > 0 {
+			requirements = append(requirements, i18n.Errorf("%d %s", opts.MinNumbers, i18n.Text(pluralize("number", opts.MinNumbers))))
+		}
+		if opts.MinSymbols > 0 {
+			requirements = append(requirements, i18n.Errorf("%d %s", opts.MinSymbols, i18n.Text(pluralize("symbol", opts.MinSymbols))))
+		}
+		if opts.MinUppercase > 0 {
+			requirements = append(requirements, i18n.Errorf("%d %s", opts.MinUppercase, i18n.Text(pluralize("uppercase letter", opts.MinUppercase))))
+		}
+		if opts.MinLowercase > 0 {
+			requirements = append(requirements, i18n.Errorf("%d %s", opts.MinLowercase, i18n.Text(pluralize("lowercase letter", opts.MinLowercase))))
+		}
+	}
+
+	if !opts.PasswordCanIncludeUsername {
+		return i18n.Errorf("the password must contain %s and must not include the username", requirements)
+	}
+
+	return i18n.Errorf("the password must contain %s", requirements)
+}
+
+// ValidateNewPassword checks a new password against the password policy
+// configured in opts and returns the first violation.
+func ValidateNewPassword(password, username string, opts *options.Opts) error {
+	errs := ValidateNewPasswordAll(password, username, opts)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if opts.IncludePolicyInErrors {
+		return i18n.Errorf("%s (%s)", errs[0], policySummary(opts))
+	}
+
+	return errs[0]
+}
+
+// ValidateNewPasswordAll checks a new password against every rule of the
+// password policy configured in opts and returns all violations.
+func ValidateNewPasswordAll(password, username string, opts *options.Opts) []error {
+	var errs []error
+
+	if len(password) < int(opts.MinLength) {
+		errs = append(errs, i18n.Errorf("the new password must be at least %d characters long", opts.MinLength))
+	}
+
+	if opts.MaxLength > 0 && len(password) > int(opts.MaxLength) {
+		errs = append(errs, i18n.Errorf("the new password must be at most %d characters long", opts.MaxLength))
+	}
+
+	if opts.MinCharacterClasses > 0 {
+		// The character class rule replaces the individual minimums.
+		if missing := missingCharacterClasses(password); uint(4-len(missing)) < opts.MinCharacterClasses {
+			errs = append(errs, i18n.Errorf("the new password must contain at least %d of the character classes numbers, symbols, uppercase and lowercase letters, it is missing %s", opts.MinCharacterClasses, missing))
+		}
+	} else {
+		if !validators.MinNumbersInString(password, opts.MinNumbers) {
+			errs = append(errs, i18n.Errorf("the new password must contain at least %d %s", opts.MinNumbers, i18n.Text(pluralize("number", opts.MinNumbers))))
+		}
+
+		if !validators.MinSymbolsInString(password, opts.MinSymbols) {
+			errs = append(errs, i18n.Errorf("the new password must contain at least %d %s", opts.MinSymbols, i18n.Text(pluralize("symbol", opts.MinSymbols))))
+		}
+
+		if !validators.MinUppercaseLettersInString(password, opts.MinUppercase) {
+			errs = append(errs, i18n.Errorf("the new password must contain at least %d %s", opts.MinUppercase, i18n.Text(pluralize("uppercase letter", opts.MinUppercase))))
+		}
+
+		if !validators.MinLowercaseLettersInString(password, opts.MinLowercase) {
+			errs = append(errs, i18n.Errorf("the new password must contain at least %d %s", opts.MinLowercase, i18n.Text(pluralize("lowercase letter", opts.MinLowercase))))
+		}
+	}
+
+	if opts.MaxRepeatedChars > 0 && validators.HasRepeatedRun(password, int(opts.MaxRepeatedChars)) {
+		errs = append(errs, i18n.Errorf("the new password must not repeat the same character more than %d %s in a row", opts.MaxRepeatedChars, i18n.Text(pluralize("time", opts.MaxRepeatedChars))))
+	}
+
+	if opts.MaxSequentialChars > 0 && validators.HasSequentialRun(password, int(opts.MaxSequentialChars)) {
+		errs = append(errs, i18n.Errorf("the new password must not contain sequences like \"abc\" or \"987\" longer than %d %s", opts.MaxSequentialChars, i18n.Text(pluralize("character", opts.MaxSequentialChars))))
+	}
+
+	if !opts.PasswordCanIncludeUsername && username != "" {
+		if strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+			errs = append(errs, i18n.Errorf("the new password must not include the username"))
+		} else if opts.StrictUsernameCheck && validators.ContainsUsernameVariant(password, username) {
+			errs = append(errs, i18n.Errorf("the new password must not include the username, not even reversed or with substituted characters"))
+		}
+	}
+
+	if opts.RejectCommonPasswords && validators.IsCommonPassword(password) {
+		errs = append(errs, i18n.Errorf("the new password is too common, please choose something less predictable"))
+	}
+
+	if opts.BreachedPasswords != nil && opts.BreachedPasswords.MayContain(password) {
+		errs = append(errs, i18n.Errorf("the new password has appeared in a data breach, please choose a different one"))
+	}
+
+	if opts.MinStrengthScore > 0 {
+		if score, _ := strength.Estimate(password, username); uint(score) < opts.MinStrengthScore {
+			errs = append(errs, i18n.Errorf("the new password is too easy to guess, please choose a longer or less predictable one"))
+		}
+	}
+
+	return errs
+}
+
+// checkAccountEnabled makes sure the account exists and is enabled. Missing and
+// disabled accounts produce the same error, so this can't be used to find out
+// which accounts exist.
+func (c *Handler) checkAccountEnabled(sAMAccountName string) error {
+	user, err := c.ldap.FindUserBySAMAccountName(sAMAccountName)
+	if errors.Is(err, ldap.ErrUserNotFound) {
+		return ErrAccountNotChangeable
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.Enabled {
+		return ErrAccountNotChangeable
+	}
+
+	return nil
+}
+
+// isBusyLDAPError tells whether the server refused to process a request
+// because it was busy, which tends to go away on an immediate retry. The
+// request wasn't applied then, so it is safe to send it again.
+func isBusyLDAPError(err error) bool {
+	return ldapv3.IsErrorAnyOf(err, ldapv3.LDAPResultBusy, ldapv3.LDAPResultUnavailable)
+}
+
+// isConnectionLDAPError tells whether the connection broke, in which case the
+// request may or may not have been applied.
+func isConnectionLDAPError(err error) bool {
+	return ldapv3.IsErrorAnyOf(err, ldapv3.LDAPResultServerDown, ldapv3.LDAPResultConnectError, ldapv3.ErrorNetwork)
+}
+
+// changePassword changes the password in the directory and retries once if
+// the server was busy. A password change isn't idempotent, so it isn't sent
+// again after the connection broke, neither here nor by FailoverClient.
+// Instead, the new password is checked to find out whether the change was
+// applied before that.
+func (c *Handler) changePassword(sAMAccountName, currentPassword, newPassword string, req Request) error {
+	err := c.ldap.ChangePasswordForSAMAccountName(sAMAccountName, currentPassword, newPassword)
+	switch {
+	case isServerUnreachable(err):
+		// The change was never sent.
+		return err
+	case isBusyLDAPError(err):
+		req.Logger.Warn("retrying password change after the LDAP server was busy", "username", sAMAccountName, "err", err)
+
+		return c.ldap.ChangePasswordForSAMAccountName(sAMAccountName, currentPassword, newPassword)
+	case isConnectionLDAPError(err):
+		if _, checkErr := c.ldap.CheckPasswordForSAMAccountName(sAMAccountName, newPassword); checkErr == nil {
+			req.Logger.Warn("password change was applied before the connection broke", "username", sAMAccountName, "err", err)
+
+			return nil
+		}
+
+		return err
+	default:
+		return err
+	}
+}
+
+func (c *Handler) changePasswordWithIP(params []string, req Request) ([]string, error) {
+	start := time.Now()
+
+	data, err := c.tryChangePassword(params, req)
+
+	event := audit.Event{
+		Action:    audit.ActionChangePassword,
+		ClientIP:  req.ClientIP,
+		RequestID: req.ID,
+		Outcome:   audit.OutcomeSuccess,
+	}
+	if len(params) > 0 {
+		event.Username = params[0]
+	}
+	if err != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Reason = err.Error()
+	}
+	c.audit.Log(event)
+
+	if err == nil && c.webhook != nil {
+		c.webhook.Notify(webhook.Event{
+			Event:    webhook.EventPasswordChanged,
+			Username: event.Username,
+			IP:       req.ClientIP,
+		})
+	}
+
+	// Every failure that isn't caused by the password policy produces the
+	// same message and takes at least the same time, so the response doesn't
+	// tell whether the account exists or the current password was wrong.
+	// Lockouts apply to any account name, so they don't reveal anything either.
+	if !c.opts.EnumerationResistance || err == nil || errors.As(err, &policyError{}) || errors.Is(err, ErrTooManyAttempts) {
+		return data, err
+	}
+
+	req.Logger.Warn("could not change password", "username", event.Username, "err", err)
+	time.Sleep(time.Until(start.Add(c.opts.EnumerationResistanceDelay)))
+
+	return nil, ErrPasswordNotChanged
+}
+
+func (c *Handler) tryChangePassword(params []string, req Request) (data []string, err error) {
 	if len(params) != 3 {
 		return nil, ErrInvalidArgumentCount
 	}
@@ -25,48 +292,98 @@ func (c *Handler) changePassword(params []string) ([]string, error) {
 	newPassword := params[2]
 
 	if sAMAccountName == "" {
-		return nil, fmt.Errorf("the username can't be empty")
+		return nil, ErrEmptyUsername
 	}
 
 	if currentPassword == "" {
-		return nil, fmt.Errorf("the old password can't be empty")
+		return nil, ErrEmptyOldPassword
 	}
 
 	if newPassword == "" {
-		return nil, fmt.Errorf("the new password can't be empty")
+		return nil, ErrEmptyNewPassword
 	}
 
-	if currentPassword == newPassword {
-		return nil, fmt.Errorf("the old password can't be same as the new one")
+	// The policy is loaded once, so a concurrent reload can't mix two
+	// policies within one request.
+	policy := c.policy.Load()
+
+	if !policy.AllowSamePassword && currentPassword == newPassword {
+		return nil, policyError{i18n.Errorf("the old password can't be same as the new one")}
 	}
 
-	if len(newPassword) < int(c.opts.MinLength) {
-		return nil, fmt.Errorf("the new password must be at least %d characters long", c.opts.MinLength)
+	// Only the distance is compared, so neither password ends up in an error
+	// or a log message.
+	if policy.MinChangedChars > 0 && validators.EditDistance(currentPassword, newPassword) < int(policy.MinChangedChars) {
+		return nil, policyError{i18n.Errorf("the new password must differ from the current one in at least %d %s", policy.MinChangedChars, i18n.Text(pluralize("character", policy.MinChangedChars)))}
 	}
 
-	if !validators.MinNumbersInString(newPassword, c.opts.MinNumbers) {
-		return nil, fmt.Errorf("the new password must contain at least %d %s", c.opts.MinNumbers, pluralize("number", c.opts.MinNumbers))
+	if policy.ReportAllViolations {
+		if errs := ValidateNewPasswordAll(newPassword, sAMAccountName, policy); len(errs) > 0 {
+			if policy.IncludePolicyInErrors {
+				errs = append(errs, policySummary(policy))
+			}
+
+			return nil, policyError{errors.Join(errs...)}
+		}
+	} else if err := ValidateNewPassword(newPassword, sAMAccountName, policy); err != nil {
+		return nil, policyError{err}
 	}
 
-	if !validators.MinSymbolsInString(newPassword, c.opts.MinSymbols) {
-		return nil, fmt.Errorf("the new password must contain at least %d %s", c.opts.MinSymbols, pluralize("symbol", c.opts.MinSymbols))
+	if c.lockout != nil {
+		if !c.lockout.Attempt(sAMAccountName) {
+			return nil, ErrTooManyAttempts
+		}
+
+		// Only wrong credentials count towards the lockout, an unreachable
+		// directory mustn't lock anybody out.
+		defer func() {
+			switch {
+			case err == nil:
+				c.lockout.RecordSuccess(sAMAccountName)
+			case !ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials):
+				c.lockout.Forgive(sAMAccountName)
+			}
+		}()
 	}
 
-	if !validators.MinUppercaseLettersInString(newPassword, c.opts.MinUppercase) {
-		return nil, fmt.Errorf("the new password must contain at least %d uppercase %s", c.opts.MinUppercase, pluralize("letter", c.opts.MinUppercase))
+	if c.cooldown != nil {
+		if remaining := c.cooldown.Remaining(sAMAccountName, time.Now()); remaining > 0 {
+			minutes := uint(math.Ceil(remaining.Minutes()))
+			return nil, i18n.Errorf("%s, try again in %d %s", ErrPasswordTooYoung, minutes, i18n.Text(pluralize("minute", minutes)))
+		}
 	}
 
-	if !validators.MinLowercaseLettersInString(newPassword, c.opts.MinLowercase) {
-		return nil, fmt.Errorf("the new password must contain at least %d lowercase %s", c.opts.MinLowercase, pluralize("letter", c.opts.MinLowercase))
+	if c.opts.RequireEnabledAccount {
+		if err := c.checkAccountEnabled(sAMAccountName); err != nil {
+			return nil, err
+		}
 	}
 
-	if !c.opts.PasswordCanIncludeUsername && strings.Contains(sAMAccountName, newPassword) {
-		return nil, fmt.Errorf("the new password must not include the username")
+	if c.history != nil {
+		// The history may only be compared once the current password proved
+		// that the request comes from the owner of the account, otherwise it
+		// would reveal previous passwords.
+		if _, err := c.ldap.CheckPasswordForSAMAccountName(sAMAccountName, currentPassword); err != nil {
+			return nil, err
+		}
+
+		if c.history.Contains(sAMAccountName, newPassword) {
+			return nil, policyError{ErrRecentPassword}
+		}
 	}
 
-	if err := c.ldap.ChangePasswordForSAMAccountName(sAMAccountName, currentPassword, newPassword); err != nil {
+	if err := c.changePassword(sAMAccountName, currentPassword, newPassword, req); err != nil {
 		return nil, err
 	}
 
+	if c.cooldown != nil {
+		c.cooldown.RecordChange(sAMAccountName, time.Now())
+	}
+	if c.history != nil {
+		if err := c.history.RecordChange(sAMAccountName, currentPassword, newPassword); err != nil {
+			req.Logger.Error("could not record the password history", "err", err)
+		}
+	}
+
 	return []string{"password changed successfully"}, nil
 }
@@ -0,0 +1,776 @@
+package rpc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/audit"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/webhook"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+func defaultOpts() *options.Opts {
+	return &options.Opts{
+		ChangePasswordEnabled: true,
+		MinLength:             8,
+		MinNumbers:            1,
+		MinSymbols:            1,
+		MinUppercase:          1,
+		MinLowercase:          1,
+	}
+}
+
+func TestValidateNewPasswordBreached(t *testing.T) {
+	opts := defaultOpts()
+	opts.BreachedPasswords = validators.NewBloomFilter(1<<16, 7)
+	opts.BreachedPasswords.Add("Password1!")
+
+	if err := rpc.ValidateNewPassword("Password1!", "jdoe", opts); err == nil {
+		t.Error("expected breached password to be rejected")
+	}
+
+	if err := rpc.ValidateNewPassword("Tr0ub4dor&3", "jdoe", opts); err != nil {
+		t.Errorf("expected password to be accepted, got %v", err)
+	}
+}
+
+func TestChangePasswordRequireEnabledAccount(t *testing.T) {
+	client := &mockLDAP{users: map[string]*ldap.User{
+		"enabled":  {SAMAccountName: "enabled", Enabled: true},
+		"disabled": {SAMAccountName: "disabled", Enabled: false},
+	}}
+
+	opts := defaultOpts()
+	opts.RequireEnabledAccount = true
+	h := newHandler(t, opts, client)
+
+	status, _ := call(t, h, changePasswordBody("enabled", "Old-Passw0rd", "New-Passw0rd"))
+	if status != http.StatusOK {
+		t.Errorf("expected enabled account to be changed, got status %d", status)
+	}
+
+	_, disabled := call(t, h, changePasswordBody("disabled", "Old-Passw0rd", "New-Passw0rd"))
+	_, missing := call(t, h, changePasswordBody("missing", "Old-Passw0rd", "New-Passw0rd"))
+	if disabled.Success || missing.Success {
+		t.Fatal("expected disabled and missing accounts to be rejected")
+	}
+	if disabled.Data[0] != missing.Data[0] {
+		t.Errorf("expected identical errors, got %q and %q", disabled.Data[0], missing.Data[0])
+	}
+
+	if len(client.changed) != 1 || client.changed[0] != "enabled" {
+		t.Errorf("expected only the enabled account to be changed, got %v", client.changed)
+	}
+}
+
+func TestValidateNewPasswordIncludesPolicy(t *testing.T) {
+	opts := defaultOpts()
+
+	err := rpc.ValidateNewPassword("short", "jdoe", opts)
+	if err == nil || strings.Contains(err.Error(), rpc.PolicySummary(opts)) {
+		t.Errorf("expected a terse error by default, got %v", err)
+	}
+
+	opts.IncludePolicyInErrors = true
+
+	err = rpc.ValidateNewPassword("short", "jdoe", opts)
+	if err == nil || !strings.Contains(err.Error(), rpc.PolicySummary(opts)) {
+		t.Errorf("expected the policy summary to be appended, got %v", err)
+	}
+
+	expected := "the password must contain at least 8 characters, 1 number, 1 symbol, 1 uppercase letter, 1 lowercase letter and must not include the username"
+	if actual := rpc.PolicySummary(opts); actual != expected {
+		t.Errorf("expected summary %q, got %q", expected, actual)
+	}
+}
+
+func TestChangePasswordSamePassword(t *testing.T) {
+	cases := []struct {
+		AllowSamePassword bool
+		Expected          bool
+	}{
+		{AllowSamePassword: false, Expected: false},
+		{AllowSamePassword: true, Expected: true},
+	}
+
+	for _, c := range cases {
+		opts := defaultOpts()
+		opts.AllowSamePassword = c.AllowSamePassword
+
+		_, res := call(t, newHandler(t, opts, &mockLDAP{}), changePasswordBody("jdoe", "Same-Passw0rd", "Same-Passw0rd"))
+		if res.Success != c.Expected {
+			t.Errorf("expected success %t with AllowSamePassword=%t, got %v", c.Expected, c.AllowSamePassword, res.Data)
+		}
+	}
+}
+
+func TestChangePasswordEnumerationResistance(t *testing.T) {
+	client := &mockLDAP{
+		users: map[string]*ldap.User{"disabled": {SAMAccountName: "disabled", Enabled: false}},
+		err:   errInvalidCredentials,
+	}
+
+	opts := defaultOpts()
+	opts.RequireEnabledAccount = true
+	opts.EnumerationResistance = true
+	opts.EnumerationResistanceDelay = 20 * time.Millisecond
+	h := newHandler(t, opts, client)
+
+	bodies := []string{
+		changePasswordBody("", "Old-Passw0rd", "New-Passw0rd"),
+		changePasswordBody("missing", "Old-Passw0rd", "New-Passw0rd"),
+		changePasswordBody("disabled", "Old-Passw0rd", "New-Passw0rd"),
+	}
+
+	client.users["jdoe"] = &ldap.User{SAMAccountName: "jdoe", Enabled: true}
+	bodies = append(bodies, changePasswordBody("jdoe", "Wrong-Passw0rd", "New-Passw0rd"))
+
+	for _, body := range bodies {
+		start := time.Now()
+		status, res := call(t, h, body)
+
+		if status != http.StatusBadRequest || len(res.Data) != 1 || res.Data[0] != rpc.ErrPasswordNotChanged.Error() {
+			t.Errorf("expected generic error for %s, got %d %v", body, status, res.Data)
+		}
+		if elapsed := time.Since(start); elapsed < opts.EnumerationResistanceDelay {
+			t.Errorf("expected response to take at least %s, took %s", opts.EnumerationResistanceDelay, elapsed)
+		}
+	}
+
+	// Policy violations are still reported as-is.
+	_, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "short"))
+	if res.Data[0] == rpc.ErrPasswordNotChanged.Error() {
+		t.Error("expected policy violation to be reported")
+	}
+}
+
+func TestValidateNewPasswordCharacterClasses(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinCharacterClasses = 3
+
+	cases := []struct {
+		Input    string
+		Expected bool
+	}{
+		// Satisfies 3 of 4 classes, even though there is no symbol.
+		{Input: "Abcdefg1", Expected: true},
+		{Input: "abcdefg1!", Expected: true},
+		{Input: "abcdefgh1", Expected: false},
+		{Input: "abcdefghi", Expected: false},
+	}
+
+	for _, c := range cases {
+		err := rpc.ValidateNewPassword(c.Input, "jdoe", opts)
+		if (err == nil) != c.Expected {
+			t.Errorf("expected valid=%t for %q, got %v", c.Expected, c.Input, err)
+		}
+	}
+
+	err := rpc.ValidateNewPassword("abcdefgh1", "jdoe", opts)
+	if err == nil || !strings.Contains(err.Error(), "symbols, uppercase letters") {
+		t.Errorf("expected error to name the missing classes, got %v", err)
+	}
+}
+
+func TestValidateNewPasswordCommon(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinSymbols = 0
+	opts.MinUppercase = 0
+
+	if err := rpc.ValidateNewPassword("baseball1", "jdoe", opts); err != nil {
+		t.Errorf("expected common password to be accepted by default, got %v", err)
+	}
+
+	opts.RejectCommonPasswords = true
+
+	if err := rpc.ValidateNewPassword("baseball1", "jdoe", opts); err == nil {
+		t.Error("expected common password to be rejected")
+	}
+}
+
+func TestValidateNewPasswordRuns(t *testing.T) {
+	opts := defaultOpts()
+
+	if err := rpc.ValidateNewPassword("Aaaaaaa1!", "jdoe", opts); err != nil {
+		t.Errorf("expected runs to be accepted by default, got %v", err)
+	}
+
+	opts.MaxRepeatedChars = 3
+	opts.MaxSequentialChars = 3
+
+	for _, password := range []string{"Aaaaaaa1!", "Abcdefg1!", "Qx!9876zz"} {
+		if err := rpc.ValidateNewPassword(password, "jdoe", opts); err == nil {
+			t.Errorf("expected %q to be rejected", password)
+		}
+	}
+}
+
+func TestChangePasswordReportAllViolations(t *testing.T) {
+	opts := defaultOpts()
+
+	_, res := call(t, newHandler(t, opts, &mockLDAP{}), changePasswordBody("jdoe", "Old-Passw0rd", "abc"))
+	if len(res.Data) != 1 {
+		t.Errorf("expected only the first violation by default, got %v", res.Data)
+	}
+
+	opts.ReportAllViolations = true
+
+	_, res = call(t, newHandler(t, opts, &mockLDAP{}), changePasswordBody("jdoe", "Old-Passw0rd", "abc"))
+	if len(res.Data) != len(rpc.ValidateNewPasswordAll("abc", "jdoe", opts)) || len(res.Data) < 2 {
+		t.Errorf("expected every violation, got %v", res.Data)
+	}
+}
+
+func TestChangePasswordAudit(t *testing.T) {
+	opts := defaultOpts()
+	opts.AuditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	h := newHandler(t, opts, &mockLDAP{})
+
+	call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "short"))
+
+	raw, err := os.ReadFile(opts.AuditLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit events, got %d", len(lines))
+	}
+
+	for _, secret := range []string{"Old-Passw0rd", "New-Passw0rd", "short"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("audit log must not contain passwords, found %q", secret)
+		}
+	}
+
+	var event audit.Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Username != "jdoe" || event.Outcome != audit.OutcomeFailure || event.Action != audit.ActionChangePassword {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestChangePasswordLockout(t *testing.T) {
+	client := &mockLDAP{err: errInvalidCredentials}
+
+	opts := defaultOpts()
+	opts.LockoutThreshold = 2
+	opts.LockoutDuration = time.Hour
+	h := newHandler(t, opts, client)
+	defer h.Close()
+
+	for i := 0; i < 2; i++ {
+		_, res := call(t, h, changePasswordBody("jdoe", "Wrong-Passw0rd", "New-Passw0rd"))
+		if res.Data[0] == rpc.ErrTooManyAttempts.Error() {
+			t.Fatalf("expected attempt %d to reach LDAP", i+1)
+		}
+	}
+
+	// The correct password doesn't help once the account is locked.
+	client.err = nil
+
+	status, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	if res.Success || res.Data[0] != rpc.ErrTooManyAttempts.Error() {
+		t.Errorf("expected account to be locked, got %v", res.Data)
+	}
+	if status != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", status)
+	}
+	if len(client.changed) != 0 {
+		t.Error("expected LDAP to not be contacted for a locked account")
+	}
+
+	_, res = call(t, h, changePasswordBody("other", "Old-Passw0rd", "New-Passw0rd"))
+	if !res.Success {
+		t.Errorf("expected other accounts to be unaffected, got %v", res.Data)
+	}
+}
+
+func TestChangePasswordLockoutIgnoresTransportErrors(t *testing.T) {
+	client := &mockLDAP{err: ldapv3.NewError(ldapv3.LDAPResultConnectError, errors.New("connection refused"))}
+
+	opts := defaultOpts()
+	opts.LockoutThreshold = 2
+	opts.LockoutDuration = time.Hour
+	h := newHandler(t, opts, client)
+	defer h.Close()
+
+	for i := 0; i < 3; i++ {
+		call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	}
+
+	// The directory is reachable again, and the account isn't locked.
+	client.err = nil
+
+	if _, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd")); !res.Success {
+		t.Errorf("expected transport errors to not lock the account, got %v", res.Data)
+	}
+}
+
+func TestChangePasswordErrorStatus(t *testing.T) {
+	cases := []struct {
+		Name         string
+		Client       *mockLDAP
+		Configure    func(opts *options.Opts)
+		Params       []string
+		Expected     int
+		ExpectedCode string
+	}{
+		{Name: "argument count", Client: &mockLDAP{}, Params: []string{"jdoe"}, Expected: http.StatusBadRequest, ExpectedCode: rpc.CodeInvalidArgument},
+		{Name: "empty field", Client: &mockLDAP{}, Params: []string{"", "Old-Passw0rd", "New-Passw0rd"}, Expected: http.StatusBadRequest, ExpectedCode: rpc.CodeInvalidArgument},
+		{Name: "policy", Client: &mockLDAP{}, Params: []string{"jdoe", "Old-Passw0rd", "short"}, Expected: http.StatusBadRequest, ExpectedCode: rpc.CodePolicyViolation},
+		{Name: "ldap", Client: &mockLDAP{err: errors.New("connection refused")}, Params: []string{"jdoe", "Old-Passw0rd", "New-Passw0rd"}, Expected: http.StatusInternalServerError, ExpectedCode: rpc.CodeLDAPError},
+		{Name: "invalid credentials", Client: &mockLDAP{err: errInvalidCredentials}, Params: []string{"jdoe", "Wrong-Passw0rd", "New-Passw0rd"}, Expected: http.StatusUnauthorized, ExpectedCode: rpc.CodeInvalidCredentials},
+		{
+			Name:         "account not changeable",
+			Client:       &mockLDAP{},
+			Configure:    func(opts *options.Opts) { opts.RequireEnabledAccount = true },
+			Params:       []string{"jdoe", "Old-Passw0rd", "New-Passw0rd"},
+			Expected:     http.StatusForbidden,
+			ExpectedCode: rpc.CodeAccountNotChangeable,
+		},
+		{
+			Name:   "enumeration resistance",
+			Client: &mockLDAP{err: errInvalidCredentials},
+			Configure: func(opts *options.Opts) {
+				opts.EnumerationResistance = true
+				opts.EnumerationResistanceDelay = 0
+			},
+			Params:       []string{"jdoe", "Wrong-Passw0rd", "New-Passw0rd"},
+			Expected:     http.StatusBadRequest,
+			ExpectedCode: rpc.CodePasswordNotChanged,
+		},
+		{
+			Name:   "enumeration resistance with ldap error",
+			Client: &mockLDAP{err: errors.New("connection refused")},
+			Configure: func(opts *options.Opts) {
+				opts.EnumerationResistance = true
+				opts.EnumerationResistanceDelay = 0
+			},
+			Params:       []string{"jdoe", "Old-Passw0rd", "New-Passw0rd"},
+			Expected:     http.StatusBadRequest,
+			ExpectedCode: rpc.CodePasswordNotChanged,
+		},
+		{
+			Name:   "lockout",
+			Client: &mockLDAP{err: errInvalidCredentials},
+			Configure: func(opts *options.Opts) {
+				opts.LockoutThreshold = 1
+				opts.LockoutDuration = time.Hour
+			},
+			Params:       []string{"jdoe", "Wrong-Passw0rd", "New-Passw0rd"},
+			Expected:     http.StatusTooManyRequests,
+			ExpectedCode: rpc.CodeTooManyAttempts,
+		},
+	}
+
+	for _, c := range cases {
+		opts := defaultOpts()
+		if c.Configure != nil {
+			c.Configure(opts)
+		}
+		h := newHandler(t, opts, c.Client)
+
+		// The lockout only applies after the first failed attempt.
+		if opts.LockoutThreshold > 0 {
+			call(t, h, changePasswordBody(c.Params...))
+		}
+
+		status, res := call(t, h, changePasswordBody(c.Params...))
+		if res.Success {
+			t.Errorf("%s: expected failure", c.Name)
+		}
+		if status != c.Expected {
+			t.Errorf("%s: expected status %d, got %d", c.Name, c.Expected, status)
+		}
+		if res.Code != c.ExpectedCode {
+			t.Errorf("%s: expected code %q, got %q", c.Name, c.ExpectedCode, res.Code)
+		}
+		h.Close()
+	}
+}
+
+func TestChangePasswordWebhook(t *testing.T) {
+	var payloads []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+	}))
+	defer server.Close()
+
+	opts := defaultOpts()
+	opts.WebhookURL = server.URL
+	h := newHandler(t, opts, &mockLDAP{})
+
+	call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "short"))
+	h.Close()
+
+	if len(payloads) != 1 {
+		t.Fatalf("expected only the successful change to be posted, got %v", payloads)
+	}
+	if payloads[0]["event"] != webhook.EventPasswordChanged || payloads[0]["username"] != "jdoe" {
+		t.Errorf("unexpected payload: %v", payloads[0])
+	}
+}
+
+func TestChangePasswordMinPasswordAge(t *testing.T) {
+	client := &mockLDAP{}
+
+	opts := defaultOpts()
+	opts.MinPasswordAge = time.Hour
+	h := newHandler(t, opts, client)
+	defer h.Close()
+
+	if _, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd")); !res.Success {
+		t.Fatalf("expected first change to succeed, got %v", res.Data)
+	}
+
+	status, res := call(t, h, changePasswordBody("jdoe", "New-Passw0rd", "Newer-Passw0rd"))
+	if res.Success || !strings.HasPrefix(res.Data[0], rpc.ErrPasswordTooYoung.Error()) || !strings.HasSuffix(res.Data[0], "try again in 60 minutes") {
+		t.Errorf("expected change within the cooldown to be rejected, got %v", res.Data)
+	}
+	if status != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", status)
+	}
+	if len(client.changed) != 1 {
+		t.Error("expected LDAP to not be contacted within the cooldown")
+	}
+
+	if _, res := call(t, h, changePasswordBody("other", "Old-Passw0rd", "New-Passw0rd")); !res.Success {
+		t.Errorf("expected other accounts to be unaffected, got %v", res.Data)
+	}
+}
+
+func TestValidateNewPasswordStrength(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinStrengthScore = 3
+
+	if err := rpc.ValidateNewPassword("Password123!", "jdoe", opts); err == nil {
+		t.Error("expected variation of a common password to be too weak")
+	}
+	if err := rpc.ValidateNewPassword("v9#Lq2!xTz@8mW", "jdoe", opts); err != nil {
+		t.Errorf("expected strong password to be accepted, got %v", err)
+	}
+}
+
+func TestValidateNewPasswordMaxLength(t *testing.T) {
+	opts := defaultOpts()
+	opts.MaxLength = 12
+
+	if err := rpc.ValidateNewPassword("Passw0rd!abc", "jdoe", opts); err != nil {
+		t.Errorf("expected password of maximum length to be accepted, got %v", err)
+	}
+	if err := rpc.ValidateNewPassword("Passw0rd!abcd", "jdoe", opts); err == nil || err.Error() != "the new password must be at most 12 characters long" {
+		t.Errorf("expected too long password to be rejected, got %v", err)
+	}
+}
+
+func TestChangePasswordHistory(t *testing.T) {
+	client := &mockLDAP{}
+
+	opts := defaultOpts()
+	opts.PasswordHistoryCount = 3
+	h := newHandler(t, opts, client)
+
+	if _, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd")); !res.Success {
+		t.Fatalf("expected first change to succeed, got %v", res.Data)
+	}
+
+	for _, reused := range []string{"Old-Passw0rd", "New-Passw0rd"} {
+		status, res := call(t, h, changePasswordBody("jdoe", "New-Passw0rd", reused))
+		if res.Success || res.Code != rpc.CodePolicyViolation {
+			t.Errorf("expected reusing %q to be rejected, got %v", reused, res.Data)
+		}
+		if status != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", status)
+		}
+	}
+
+	if _, res := call(t, h, changePasswordBody("jdoe", "New-Passw0rd", "Fresh-Passw0rd")); !res.Success {
+		t.Errorf("expected a fresh password to be accepted, got %v", res.Data)
+	}
+	if len(client.changed) != 2 {
+		t.Errorf("expected 2 changes, got %d", len(client.changed))
+	}
+}
+
+func TestChangePasswordHistoryRequiresCurrentPassword(t *testing.T) {
+	client := &mockLDAP{}
+
+	opts := defaultOpts()
+	opts.PasswordHistoryCount = 3
+	h := newHandler(t, opts, client)
+
+	if _, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd")); !res.Success {
+		t.Fatalf("expected first change to succeed, got %v", res.Data)
+	}
+
+	// Someone without the current password must not learn previous ones.
+	client.err = errInvalidCredentials
+	if _, res := call(t, h, changePasswordBody("jdoe", "Guessed-Passw0rd", "Old-Passw0rd")); res.Code != rpc.CodeInvalidCredentials {
+		t.Errorf("expected the failed authentication to be reported, got %q with %v", res.Code, res.Data)
+	}
+}
+
+// failingHistory rejects one password and can't record changes.
+type failingHistory struct {
+	rejected string
+}
+
+func (f failingHistory) Contains(account, password string) bool {
+	return password == f.rejected
+}
+
+func (f failingHistory) RecordChange(account, oldPassword, newPassword string) error {
+	return errors.New("history store is unavailable")
+}
+
+func TestChangePasswordCustomHistory(t *testing.T) {
+	var out bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	h := newHandler(t, defaultOpts(), &mockLDAP{})
+	h.SetPasswordHistory(failingHistory{rejected: "Old-Passw0rd!"})
+
+	app := fiber.New()
+	app.Use(rpc.RequestID())
+	app.Post("/api/rpc", h.Handle)
+
+	change := func(newPassword string) rpc.JSONRPCResponse {
+		req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(changePasswordBody("jdoe", "Current-Passw0rd", newPassword)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(fiber.HeaderXRequestID, "support-ticket-42")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var body rpc.JSONRPCResponse
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		return body
+	}
+
+	if res := change("Old-Passw0rd!"); res.Success || res.Code != rpc.CodePolicyViolation {
+		t.Errorf("expected the password from the history to be rejected, got %v", res.Data)
+	}
+
+	// A failure to record the history mustn't fail the change, which already
+	// happened.
+	if res := change("New-Passw0rd!"); !res.Success {
+		t.Errorf("expected the change to succeed, got %v", res.Data)
+	}
+	if !strings.Contains(out.String(), "could not record the password history") || !strings.Contains(out.String(), `"request_id":"support-ticket-42"`) {
+		t.Errorf("expected the failure to be logged with the request ID, got %q", out.String())
+	}
+}
+
+func TestValidateNewPasswordUsername(t *testing.T) {
+	opts := defaultOpts()
+
+	for _, password := range []string{"jdoe2024!A", "Xx-JDoe-2024!"} {
+		if err := rpc.ValidateNewPassword(password, "jdoe", opts); err == nil {
+			t.Errorf("expected %q to be rejected for containing the username", password)
+		}
+	}
+	if err := rpc.ValidateNewPassword("Correct-H0rse!", "jdoe", opts); err != nil {
+		t.Errorf("expected unrelated password to be accepted, got %v", err)
+	}
+	// The username merely containing the password used to be rejected.
+	if err := rpc.ValidateNewPassword("Do3!abcd", "xDo3!abcdx", opts); err != nil {
+		t.Errorf("expected a password that doesn't contain the username to be accepted, got %v", err)
+	}
+	if err := rpc.ValidateNewPassword("Correct-H0rse!", "", opts); err != nil {
+		t.Errorf("expected an empty username not to match, got %v", err)
+	}
+}
+
+func TestValidateNewPasswordStrictUsernameCheck(t *testing.T) {
+	opts := defaultOpts()
+
+	if err := rpc.ValidateNewPassword("Adm1n-2024!", "admin", opts); err != nil {
+		t.Errorf("expected leetspeak usernames to be accepted by default, got %v", err)
+	}
+
+	opts.StrictUsernameCheck = true
+
+	for _, password := range []string{"Adm1n-2024!", "N1mda-2024!"} {
+		if err := rpc.ValidateNewPassword(password, "admin", opts); err == nil {
+			t.Errorf("expected %q to be rejected", password)
+		}
+	}
+	if err := rpc.ValidateNewPassword("Correct-H0rse!", "admin", opts); err != nil {
+		t.Errorf("expected unrelated password to be accepted, got %v", err)
+	}
+
+	opts.PasswordCanIncludeUsername = true
+
+	if err := rpc.ValidateNewPassword("Adm1n-2024!", "admin", opts); err != nil {
+		t.Errorf("expected the username to be allowed, got %v", err)
+	}
+
+	// Without the strict check, the username is still rejected literally.
+	opts = &options.Opts{MinLength: 6}
+	if err := rpc.ValidateNewPassword("xjdoex", "jdoe", opts); err == nil {
+		t.Error("expected a password containing the username to be rejected without the strict check")
+	}
+
+	opts.PasswordCanIncludeUsername = true
+	if err := rpc.ValidateNewPassword("xjdoex", "jdoe", opts); err != nil {
+		t.Errorf("expected only the username to be rejected, got %v", err)
+	}
+}
+
+func TestChangePasswordRetriesBusyErrors(t *testing.T) {
+	busy := ldapv3.NewError(ldapv3.LDAPResultBusy, errors.New("server busy"))
+	unavailable := ldapv3.NewError(ldapv3.LDAPResultUnavailable, errors.New("unavailable"))
+
+	cases := []struct {
+		Name          string
+		Errs          []error
+		ExpectSuccess bool
+		ExpectedCalls int
+	}{
+		{Name: "busy once", Errs: []error{busy}, ExpectSuccess: true, ExpectedCalls: 2},
+		{Name: "unavailable once", Errs: []error{unavailable}, ExpectSuccess: true, ExpectedCalls: 2},
+		{Name: "busy twice", Errs: []error{busy, busy}, ExpectSuccess: false, ExpectedCalls: 2},
+		{Name: "invalid credentials", Errs: []error{errInvalidCredentials}, ExpectSuccess: false, ExpectedCalls: 1},
+	}
+
+	for _, c := range cases {
+		client := &mockLDAP{changeErrs: c.Errs}
+
+		_, res := call(t, newHandler(t, defaultOpts(), client), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+		if res.Success != c.ExpectSuccess {
+			t.Errorf("%s: expected success to be %v, got %v", c.Name, c.ExpectSuccess, res.Data)
+		}
+		if client.calls != c.ExpectedCalls {
+			t.Errorf("%s: expected %d attempts, got %d", c.Name, c.ExpectedCalls, client.calls)
+		}
+	}
+}
+
+// resetLDAP loses the connection during every password change, either before
+// or after the change was applied.
+type resetLDAP struct {
+	mockLDAP
+	password string
+	apply    bool
+}
+
+func (m *resetLDAP) CheckPasswordForSAMAccountName(sAMAccountName, password string) (*ldap.User, error) {
+	if password != m.password {
+		return nil, errInvalidCredentials
+	}
+
+	return &ldap.User{SAMAccountName: sAMAccountName, Enabled: true}, nil
+}
+
+func (m *resetLDAP) ChangePasswordForSAMAccountName(_, oldPassword, newPassword string) error {
+	m.calls++
+
+	if oldPassword != m.password {
+		return errInvalidCredentials
+	}
+	if m.apply {
+		m.password = newPassword
+	}
+
+	return ldapv3.NewError(ldapv3.ErrorNetwork, errors.New("connection reset by peer"))
+}
+
+func TestChangePasswordConnectionReset(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Apply         bool
+		ExpectSuccess bool
+	}{
+		{Name: "applied, then connection reset", Apply: true, ExpectSuccess: true},
+		{Name: "connection reset before the change", Apply: false, ExpectSuccess: false},
+	}
+
+	for _, c := range cases {
+		client := &resetLDAP{password: "Old-Passw0rd", apply: c.Apply}
+
+		_, res := call(t, newHandler(t, defaultOpts(), client), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+		if res.Success != c.ExpectSuccess {
+			t.Errorf("%s: expected success to be %v, got %v", c.Name, c.ExpectSuccess, res.Data)
+		}
+		// A retry after an applied change would fail with the old password,
+		// so the change must not be sent again.
+		if client.calls != 1 {
+			t.Errorf("%s: expected 1 attempt, got %d", c.Name, client.calls)
+		}
+	}
+}
+
+func TestChangePasswordConnectionResetWithFailover(t *testing.T) {
+	primary := &resetLDAP{password: "Old-Passw0rd", apply: true}
+	secondary := &mockLDAP{}
+
+	_, res := call(t, newHandler(t, defaultOpts(), rpc.NewFailoverClient(primary, secondary)), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	if !res.Success {
+		t.Errorf("expected the applied change to succeed, got %v", res.Data)
+	}
+	if primary.calls != 1 || secondary.calls != 0 {
+		t.Errorf("expected the change to be sent once, got %d calls to the primary and %d to the secondary", primary.calls, secondary.calls)
+	}
+}
+
+func TestChangePasswordMinChangedChars(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinChangedChars = 3
+
+	cases := []struct {
+		Old     string
+		New     string
+		Success bool
+	}{
+		{Old: "Summer2023!", New: "Summer2024!", Success: false},
+		{Old: "Summer2023!", New: "Summer2023!x", Success: false},
+		{Old: "Summer2023!", New: "Sumer2024?", Success: true},
+		{Old: "Summer2023!", New: "Winter-Garden7", Success: true},
+	}
+
+	for _, c := range cases {
+		client := &mockLDAP{}
+
+		status, res := call(t, newHandler(t, opts, client), changePasswordBody("jdoe", c.Old, c.New))
+		if res.Success != c.Success {
+			t.Errorf("%q -> %q: expected success to be %v, got %v", c.Old, c.New, c.Success, res.Data)
+		}
+		if !c.Success && (status != http.StatusBadRequest || res.Code != rpc.CodePolicyViolation || client.calls != 0) {
+			t.Errorf("%q -> %q: expected a policy violation before contacting LDAP, got %d with %q", c.Old, c.New, status, res.Code)
+		}
+		for _, message := range res.Data {
+			if strings.Contains(message, c.Old) || strings.Contains(message, c.New) {
+				t.Errorf("%q -> %q: expected the passwords not to appear in %q", c.Old, c.New, message)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range h.opts.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerAddr returns the address of the immediate peer of the request.
+func peerAddr(c *fiber.Ctx) (netip.Addr, bool) {
+	peer, ok := netip.AddrFromSlice(c.Context().RemoteIP())
+
+	return peer.Unmap(), ok
+}
+
+// ClientIP returns the address of the client that sent the request. The
+// X-Forwarded-For and X-Real-IP headers are only honored when the request
+// comes from a trusted proxy. X-Forwarded-For is walked from the right and
+// trusted proxies are skipped, so clients can't spoof their address by
+// sending the header themselves.
+func (h *Handler) ClientIP(c *fiber.Ctx) string {
+	client, ok := peerAddr(c)
+	if !ok {
+		return c.IP()
+	}
+
+	if !h.isTrustedProxy(client) {
+		return client.String()
+	}
+
+	if forwarded := c.Get(fiber.HeaderXForwardedFor); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0 && h.isTrustedProxy(client); i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+
+			client = hop.Unmap()
+		}
+
+		return client.String()
+	}
+
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(c.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+
+	return client.String()
+}
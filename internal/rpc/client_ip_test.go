@@ -0,0 +1,108 @@
+package rpc_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/valyala/fasthttp"
+)
+
+func clientIP(t *testing.T, trustedProxies, peer string, headers map[string]string) string {
+	t.Helper()
+
+	prefixes, err := options.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	opts := defaultOpts()
+	opts.TrustedProxies = prefixes
+	h := newHandler(t, opts, &mockLDAP{})
+
+	var req fasthttp.Request
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(peer), Port: 54321}, nil)
+
+	app := fiber.New()
+	c := app.AcquireCtx(fctx)
+	defer app.ReleaseCtx(c)
+
+	return h.ClientIP(c)
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		Name           string
+		TrustedProxies string
+		Peer           string
+		Headers        map[string]string
+		Expected       string
+	}{
+		{
+			Name:     "no proxies trusted",
+			Peer:     "203.0.113.5",
+			Headers:  map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"},
+			Expected: "203.0.113.5",
+		},
+		{
+			Name:           "spoofed headers from an untrusted peer",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "203.0.113.5",
+			Headers:        map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"},
+			Expected:       "203.0.113.5",
+		},
+		{
+			Name:           "trusted proxy",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "10.0.0.2",
+			Headers:        map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			Expected:       "198.51.100.1",
+		},
+		{
+			Name:           "spoofed entries in front of the real client",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "10.0.0.2",
+			Headers:        map[string]string{"X-Forwarded-For": "192.0.2.99, 198.51.100.1"},
+			Expected:       "198.51.100.1",
+		},
+		{
+			Name:           "chain of trusted proxies",
+			TrustedProxies: "10.0.0.0/8, 172.16.0.1",
+			Peer:           "10.0.0.2",
+			Headers:        map[string]string{"X-Forwarded-For": "192.0.2.99, 198.51.100.1, 172.16.0.1, 10.1.2.3"},
+			Expected:       "198.51.100.1",
+		},
+		{
+			Name:           "invalid entry stops the walk",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "10.0.0.2",
+			Headers:        map[string]string{"X-Forwarded-For": "198.51.100.1, garbage, 10.1.2.3"},
+			Expected:       "10.1.2.3",
+		},
+		{
+			Name:           "real IP header from a trusted proxy",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "10.0.0.2",
+			Headers:        map[string]string{"X-Real-IP": "198.51.100.2"},
+			Expected:       "198.51.100.2",
+		},
+		{
+			Name:           "trusted proxy without headers",
+			TrustedProxies: "10.0.0.0/8",
+			Peer:           "10.0.0.2",
+			Expected:       "10.0.0.2",
+		},
+	}
+
+	for _, c := range cases {
+		if actual := clientIP(t, c.TrustedProxies, c.Peer, c.Headers); actual != c.Expected {
+			t.Errorf("%s: expected %s, got %s", c.Name, c.Expected, actual)
+		}
+	}
+}
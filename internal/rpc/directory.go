@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+var (
+	// ErrPasswordExpiryUnknown is returned when the directory doesn't tell
+	// when a password expires.
+	ErrPasswordExpiryUnknown = errors.New("the password expiry is unknown")
+	// ErrPasswordNeverExpires is returned for passwords without an expiry.
+	ErrPasswordNeverExpires = errors.New("the password never expires")
+)
+
+// unixEpochFileTime is the Unix epoch as a Windows file time, which counts
+// 100ns intervals since 1601.
+const unixEpochFileTime = 116444736000000000
+
+// directory adds the lookups that simple-ldap-go doesn't provide to its client.
+type directory struct {
+	*ldap.LDAP
+	config ldap.Config
+}
+
+func newDirectory(config ldap.Config, user, password string) (*directory, error) {
+	client, err := ldap.New(config, user, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &directory{LDAP: client, config: config}, nil
+}
+
+// ChangePasswordForSAMAccountName connects to the server once before changing
+// the password. If that fails, the change was never sent, which the error
+// tells with ErrServerUnreachable.
+func (d *directory) ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword string) error {
+	c, err := d.GetConnection()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrServerUnreachable, err)
+	}
+	c.Close()
+
+	return d.LDAP.ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword)
+}
+
+// PasswordExpiryForSAMAccountName returns when the password of the account
+// expires. Only ActiveDirectory computes this, taking fine-grained password
+// policies into account.
+func (d *directory) PasswordExpiryForSAMAccountName(sAMAccountName string) (time.Time, error) {
+	if !d.config.IsActiveDirectory {
+		return time.Time{}, ErrPasswordExpiryUnknown
+	}
+
+	c, err := d.GetConnection()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer c.Close()
+
+	r, err := c.Search(&ldapv3.SearchRequest{
+		BaseDN:       d.config.BaseDN,
+		Scope:        ldapv3.ScopeWholeSubtree,
+		DerefAliases: ldapv3.NeverDerefAliases,
+		Filter:       fmt.Sprintf("(&(objectClass=user)(sAMAccountName=%s))", ldapv3.EscapeFilter(sAMAccountName)),
+		Attributes:   []string{"msDS-UserPasswordExpiryTimeComputed"},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(r.Entries) == 0 {
+		return time.Time{}, ldap.ErrUserNotFound
+	}
+	if len(r.Entries) > 1 {
+		return time.Time{}, ldap.ErrSAMAccountNameDuplicated
+	}
+
+	return parseFileTime(r.Entries[0].GetAttributeValue("msDS-UserPasswordExpiryTimeComputed"))
+}
+
+// parseFileTime parses a Windows file time. Zero and the maximum value mean
+// that there is no expiry.
+func parseFileTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, ErrPasswordExpiryUnknown
+	}
+
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if v <= 0 || v == math.MaxInt64 {
+		return time.Time{}, ErrPasswordNeverExpires
+	}
+
+	v -= unixEpochFileTime
+
+	return time.Unix(v/1e7, v%1e7*100).UTC(), nil
+}
@@ -1,6 +1,13 @@
 package rpc
 
-import "errors"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
 
 var ErrInvalidArgumentCount = errors.New("invalid argument count")
 
@@ -9,7 +16,91 @@ type JSONRPC struct {
 	Params []string `json:"params"`
 }
 
+// Error codes let clients tell failures apart without parsing the messages,
+// which may be translated.
+const (
+	CodeInvalidRequest       = "INVALID_REQUEST"
+	CodeMethodNotFound       = "METHOD_NOT_FOUND"
+	CodeFeatureDisabled      = "FEATURE_DISABLED"
+	CodeMaintenance          = "MAINTENANCE"
+	CodeInvalidArgument      = "INVALID_ARGUMENT"
+	CodePolicyViolation      = "POLICY_VIOLATION"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeTooManyAttempts      = "TOO_MANY_ATTEMPTS"
+	CodePasswordTooYoung     = "PASSWORD_TOO_YOUNG"
+	CodeAccountNotChangeable = "ACCOUNT_NOT_CHANGEABLE"
+	CodePasswordNotChanged   = "PASSWORD_NOT_CHANGED"
+	CodeInvalidCredentials   = "INVALID_CREDENTIALS"
+	CodeLDAPError            = "LDAP_ERROR"
+	CodeInvalidConfig        = "INVALID_CONFIG"
+)
+
 type JSONRPCResponse struct {
 	Success bool     `json:"success"`
 	Data    []string `json:"data"`
+	// Code is only set on failed requests.
+	Code string `json:"code,omitempty"`
+}
+
+// foldKey maps a key to a canonical form, in which keys are identical if
+// encoding/json considers them equal. Like strings.EqualFold, it uses Unicode
+// simple folding, so "paramſ" is the same key as "params".
+func foldKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		canonical := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < canonical {
+				canonical = f
+			}
+		}
+
+		return canonical
+	}, key)
+}
+
+// decodeJSONRPC decodes a request body, rejecting bodies that contain the same
+// top-level key more than once. encoding/json silently keeps the last value
+// (matching keys with Unicode case folding), which a proxy in front of us might
+// interpret differently.
+func decodeJSONRPC(body []byte) (JSONRPC, error) {
+	var rpc JSONRPC
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return rpc, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return rpc, fmt.Errorf("request body must be a JSON object")
+	}
+
+	seen := make(map[string]struct{})
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return rpc, err
+		}
+
+		key := foldKey(tok.(string))
+		if _, exists := seen[key]; exists {
+			return rpc, fmt.Errorf("duplicate key %q in request body", tok)
+		}
+		seen[key] = struct{}{}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return rpc, err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return rpc, err
+	}
+
+	if err := json.Unmarshal(body, &rpc); err != nil {
+		return rpc, err
+	}
+
+	return rpc, nil
 }
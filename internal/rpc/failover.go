@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+// FailoverClient passes calls to the first of several LDAP clients that can
+// be reached. The last client that worked is tried first on the next call.
+type FailoverClient struct {
+	mu      sync.Mutex
+	clients []LDAPClient
+	current int
+}
+
+func NewFailoverClient(clients ...LDAPClient) *FailoverClient {
+	return &FailoverClient{clients: clients}
+}
+
+// ErrServerUnreachable wraps errors that occurred before a password change was
+// sent to the server, so the change can safely be sent to another one.
+var ErrServerUnreachable = errors.New("the LDAP server can't be reached")
+
+func isNetworkError(err error) bool {
+	return ldapv3.IsErrorWithCode(err, ldapv3.ErrorNetwork)
+}
+
+func isServerUnreachable(err error) bool {
+	return errors.Is(err, ErrServerUnreachable)
+}
+
+// try calls fn with every client, starting with the last one that worked,
+// until a call doesn't fail with an error that failover reports as worth
+// trying the next client for.
+func (f *FailoverClient) try(failover func(err error) bool, fn func(client LDAPClient) error) error {
+	f.mu.Lock()
+	start := f.current
+	f.mu.Unlock()
+
+	var err error
+	for i := range f.clients {
+		idx := (start + i) % len(f.clients)
+
+		err = fn(f.clients[idx])
+		if failover(err) {
+			continue
+		}
+
+		f.mu.Lock()
+		f.current = idx
+		f.mu.Unlock()
+
+		return err
+	}
+
+	return err
+}
+
+func (f *FailoverClient) FindUserBySAMAccountName(sAMAccountName string) (user *ldap.User, err error) {
+	err = f.try(isNetworkError, func(client LDAPClient) error {
+		user, err = client.FindUserBySAMAccountName(sAMAccountName)
+		return err
+	})
+
+	return user, err
+}
+
+func (f *FailoverClient) CheckPasswordForSAMAccountName(sAMAccountName, password string) (user *ldap.User, err error) {
+	err = f.try(isNetworkError, func(client LDAPClient) error {
+		user, err = client.CheckPasswordForSAMAccountName(sAMAccountName, password)
+		return err
+	})
+
+	return user, err
+}
+
+// ChangePasswordForSAMAccountName only fails over while the change couldn't be
+// sent. A password change isn't idempotent, so after a network error during
+// the change it isn't sent to another server, the caller has to find out
+// whether it was applied.
+func (f *FailoverClient) ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword string) error {
+	return f.try(isServerUnreachable, func(client LDAPClient) error {
+		return client.ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword)
+	})
+}
+
+func (f *FailoverClient) PasswordExpiryForSAMAccountName(sAMAccountName string) (expiry time.Time, err error) {
+	err = f.try(isNetworkError, func(client LDAPClient) error {
+		expiry, err = client.PasswordExpiryForSAMAccountName(sAMAccountName)
+		return err
+	})
+
+	return expiry, err
+}
+
+// lazyClient connects to a server that couldn't be reached during startup
+// once it is needed.
+type lazyClient struct {
+	mu      sync.Mutex
+	connect func() (*directory, error)
+	client  *directory
+}
+
+func (l *lazyClient) get() (*directory, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.client == nil {
+		client, err := l.connect()
+		if err != nil {
+			return nil, err
+		}
+
+		l.client = client
+	}
+
+	return l.client, nil
+}
+
+func (l *lazyClient) FindUserBySAMAccountName(sAMAccountName string) (*ldap.User, error) {
+	client, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.FindUserBySAMAccountName(sAMAccountName)
+}
+
+func (l *lazyClient) CheckPasswordForSAMAccountName(sAMAccountName, password string) (*ldap.User, error) {
+	client, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CheckPasswordForSAMAccountName(sAMAccountName, password)
+}
+
+func (l *lazyClient) ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword string) error {
+	client, err := l.get()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrServerUnreachable, err)
+	}
+
+	return client.ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword)
+}
+
+func (l *lazyClient) PasswordExpiryForSAMAccountName(sAMAccountName string) (time.Time, error) {
+	client, err := l.get()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return client.PasswordExpiryForSAMAccountName(sAMAccountName)
+}
+
+// newLDAPClient connects to the configured LDAP server. If several servers
+// are configured as a comma separated list, it fails over between them and
+// only fails if none of them can be reached.
+func newLDAPClient(opts *options.Opts) (LDAPClient, error) {
+	servers := strings.Split(opts.LDAP.Server, ",")
+	if len(servers) == 1 {
+		return newDirectory(opts.LDAP, opts.ReadonlyUser, opts.ReadonlyPassword)
+	}
+
+	var (
+		clients   []LDAPClient
+		reachable bool
+		lastErr   error
+	)
+	for _, server := range servers {
+		config := opts.LDAP
+		config.Server = strings.TrimSpace(server)
+
+		connect := func() (*directory, error) {
+			return newDirectory(config, opts.ReadonlyUser, opts.ReadonlyPassword)
+		}
+
+		client, err := connect()
+		if err != nil {
+			slog.Warn("could not connect to LDAP server", "server", config.Server, "err", err)
+
+			lastErr = err
+			clients = append(clients, &lazyClient{connect: connect})
+
+			continue
+		}
+
+		reachable = true
+		clients = append(clients, client)
+	}
+
+	if !reachable {
+		return nil, lastErr
+	}
+
+	return NewFailoverClient(clients...), nil
+}
@@ -0,0 +1,58 @@
+package rpc_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestFailoverClient(t *testing.T) {
+	primary := &mockLDAP{err: fmt.Errorf("%w: %w", rpc.ErrServerUnreachable, ldapv3.NewError(ldapv3.ErrorNetwork, errors.New("connection refused")))}
+	secondary := &mockLDAP{}
+	client := rpc.NewFailoverClient(primary, secondary)
+
+	for i := 0; i < 2; i++ {
+		if err := client.ChangePasswordForSAMAccountName("jdoe", "Old-Passw0rd", "New-Passw0rd"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(secondary.changed) != 2 {
+		t.Errorf("expected secondary to change both passwords, got %v", secondary.changed)
+	}
+	if primary.calls != 1 {
+		t.Errorf("expected the last working server to be tried first, primary was called %d times", primary.calls)
+	}
+}
+
+func TestFailoverClientKeepsNonNetworkErrors(t *testing.T) {
+	invalidCredentials := ldapv3.NewError(ldapv3.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	primary := &mockLDAP{err: invalidCredentials}
+	secondary := &mockLDAP{}
+
+	err := rpc.NewFailoverClient(primary, secondary).ChangePasswordForSAMAccountName("jdoe", "Wrong-Passw0rd", "New-Passw0rd")
+	if !errors.Is(err, invalidCredentials) {
+		t.Errorf("expected invalid credentials error, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Error("expected secondary to not be tried for non-network errors")
+	}
+}
+
+func TestFailoverClientDoesNotResendChanges(t *testing.T) {
+	// The change reached the primary, but the connection broke before the
+	// response arrived.
+	primary := &mockLDAP{err: ldapv3.NewError(ldapv3.ErrorNetwork, errors.New("connection reset by peer"))}
+	secondary := &mockLDAP{}
+
+	err := rpc.NewFailoverClient(primary, secondary).ChangePasswordForSAMAccountName("jdoe", "Old-Passw0rd", "New-Passw0rd")
+	if !ldapv3.IsErrorWithCode(err, ldapv3.ErrorNetwork) {
+		t.Errorf("expected the network error, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Error("expected the change to not be sent to the secondary again")
+	}
+}
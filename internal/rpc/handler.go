@@ -1,45 +1,258 @@
 package rpc
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	ldapv3 "github.com/go-ldap/ldap/v3"
 	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/audit"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/cooldown"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/history"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/i18n"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/lockout"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/metrics"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/webhook"
 	ldap "github.com/netresearch/simple-ldap-go"
 )
 
-type Func = func(params []string) ([]string, error)
+// Request holds the details of an RPC call that aren't part of its params.
+type Request struct {
+	ID       string
+	ClientIP string
+	Logger   *slog.Logger
+}
+
+type Func = func(params []string, req Request) ([]string, error)
+
+var (
+	ErrFeatureDisabled error = i18n.Errorf("this feature is not enabled")
+	ErrMaintenance     error = i18n.Errorf("password changes are temporarily unavailable due to maintenance, please try again later")
+)
+
+// LDAPClient is the subset of the LDAP client used by the handler.
+type LDAPClient interface {
+	FindUserBySAMAccountName(sAMAccountName string) (*ldap.User, error)
+	CheckPasswordForSAMAccountName(sAMAccountName, password string) (*ldap.User, error)
+	ChangePasswordForSAMAccountName(sAMAccountName, oldPassword, newPassword string) error
+	PasswordExpiryForSAMAccountName(sAMAccountName string) (time.Time, error)
+}
+
+// PasswordHistoryChecker remembers the previous passwords of accounts, so they
+// can't be reused. The history.Tracker used by default keeps them in memory
+// only, so they are forgotten on restart and aren't shared between several
+// instances. SetPasswordHistory replaces it, e.g. with a persistent store.
+type PasswordHistoryChecker interface {
+	Contains(account, password string) bool
+	RecordChange(account, oldPassword, newPassword string) error
+}
 
 type Handler struct {
-	ldap *ldap.LDAP
-	opts *options.Opts
+	ldap     LDAPClient
+	opts     *options.Opts
+	audit    audit.Auditor
+	lockout  *lockout.Tracker
+	cooldown *cooldown.Tracker
+	history  PasswordHistoryChecker
+	webhook  *webhook.Notifier
+	started  time.Time
+
+	// maintenance rejects password changes while set, it can be toggled at
+	// runtime by admins.
+	maintenance atomic.Bool
+	// policy holds the options with the current password policy, which can
+	// be reloaded at runtime by admins.
+	policy atomic.Pointer[options.Opts]
+
+	stopCleanup []func()
 }
 
 func New(opts *options.Opts) (*Handler, error) {
-	ldap, err := ldap.New(opts.LDAP, opts.ReadonlyUser, opts.ReadonlyPassword)
+	client, err := newLDAPClient(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Handler{ldap, opts}, nil
+	return NewWithClient(opts, client)
+}
+
+func NewWithClient(opts *options.Opts, client LDAPClient) (*Handler, error) {
+	var auditor audit.Auditor = audit.Nop{}
+	if opts.AuditLogPath != "" {
+		fileAuditor, err := audit.NewFileAuditor(opts.AuditLogPath)
+		if err != nil {
+			return nil, err
+		}
+
+		auditor = fileAuditor
+	}
+
+	h := &Handler{
+		ldap:    client,
+		opts:    opts,
+		audit:   auditor,
+		started: time.Now(),
+	}
+	h.maintenance.Store(opts.MaintenanceMode)
+	h.policy.Store(opts)
+
+	if opts.LockoutThreshold > 0 {
+		h.lockout = lockout.New(opts.LockoutThreshold, opts.LockoutDuration)
+		h.stopCleanup = append(h.stopCleanup, h.lockout.StartCleanup(time.Minute))
+	}
+
+	if opts.MinPasswordAge > 0 {
+		h.cooldown = cooldown.New(opts.MinPasswordAge)
+		h.stopCleanup = append(h.stopCleanup, h.cooldown.StartCleanup(time.Minute))
+	}
+
+	if opts.PasswordHistoryCount > 0 {
+		h.history = history.New(opts.PasswordHistoryCount)
+	}
+
+	if opts.StatsInterval > 0 {
+		h.stopCleanup = append(h.stopCleanup, h.startStatsReporter(opts.StatsInterval))
+	}
+
+	if opts.WebhookURL != "" {
+		h.webhook = webhook.New(opts.WebhookURL, opts.WebhookSecret)
+		h.stopCleanup = append(h.stopCleanup, h.webhook.Close)
+	}
+
+	return h, nil
+}
+
+// SetPasswordHistory makes the handler reject passwords that history contains,
+// regardless of --password-history-count. It has to be called before the
+// handler serves requests.
+func (h *Handler) SetPasswordHistory(history PasswordHistoryChecker) {
+	h.history = history
+}
+
+// Close stops the background work of the handler.
+func (h *Handler) Close() {
+	for _, stop := range h.stopCleanup {
+		stop()
+	}
+}
+
+// errorMessages flattens errors created with errors.Join into one translated
+// message per error, so clients can show each of them separately.
+func errorMessages(err error, locale i18n.Locale) []string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return []string{i18n.Localize(err, locale)}
+	}
+
+	var messages []string
+	for _, err := range joined.Unwrap() {
+		messages = append(messages, errorMessages(err, locale)...)
+	}
+
+	return messages
+}
+
+// localeFor returns the language of the responses to a request, which can be
+// chosen with the lang query parameter or the Accept-Language header.
+func localeFor(c *fiber.Ctx) i18n.Locale {
+	if lang := c.Query("lang"); lang != "" {
+		return i18n.Parse(lang)
+	}
+
+	return i18n.FromAcceptLanguage(c.Get(fiber.HeaderAcceptLanguage))
+}
+
+// classifyError returns the HTTP status and error code that match the cause
+// of err, so that only genuine server errors are answered with a 5xx status.
+func classifyError(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrFeatureDisabled):
+		return http.StatusForbidden, CodeFeatureDisabled
+	case errors.Is(err, ErrMaintenance):
+		return http.StatusServiceUnavailable, CodeMaintenance
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests, CodeRateLimited
+	case errors.Is(err, ErrTooManyAttempts):
+		return http.StatusTooManyRequests, CodeTooManyAttempts
+	case errors.Is(err, ErrPasswordTooYoung):
+		return http.StatusTooManyRequests, CodePasswordTooYoung
+	case errors.Is(err, ErrInvalidArgumentCount),
+		errors.Is(err, ErrEmptyUsername),
+		errors.Is(err, ErrEmptyOldPassword),
+		errors.Is(err, ErrEmptyNewPassword):
+		return http.StatusBadRequest, CodeInvalidArgument
+	case errors.As(err, &policyError{}):
+		return http.StatusBadRequest, CodePolicyViolation
+	case errors.Is(err, ErrAccountNotChangeable):
+		return http.StatusForbidden, CodeAccountNotChangeable
+	case errors.Is(err, ErrPasswordNotChanged):
+		// Enumeration resistance answers every failure with this error, so
+		// its status mustn't depend on what actually went wrong.
+		return http.StatusBadRequest, CodePasswordNotChanged
+	case ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials):
+		return http.StatusUnauthorized, CodeInvalidCredentials
+	default:
+		return http.StatusInternalServerError, CodeLDAPError
+	}
+}
+
+func sendErrorResponse(c *fiber.Ctx, locale i18n.Locale, err error) error {
+	status, code := classifyError(err)
+
+	return sendResponse(c.Status(status), JSONRPCResponse{
+		Success: false,
+		Data:    errorMessages(err, locale),
+		Code:    code,
+	})
+}
+
+// sendResponse answers with JSON, unless the client prefers plain text, in
+// which case it only gets the messages, one per line.
+func sendResponse(c *fiber.Ctx, res JSONRPCResponse) error {
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextPlain) == fiber.MIMETextPlain {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(strings.Join(res.Data, "\n"))
+	}
+
+	return c.JSON(res)
 }
 
 func (h *Handler) Handle(c *fiber.Ctx) error {
-	var body JSONRPC
-	if err := c.BodyParser(&body); err != nil {
-		return err
+	body, err := decodeJSONRPC(c.Body())
+	if err != nil {
+		return sendResponse(c.Status(http.StatusBadRequest), JSONRPCResponse{
+			Success: false,
+			Data:    []string{err.Error()},
+			Code:    CodeInvalidRequest,
+		})
 	}
 
+	locale := localeFor(c)
+
 	wrapRPC := func(fn Func) error {
-		data, err := fn(body.Params)
+		data, err := fn(body.Params, Request{
+			ID:       requestIDFor(c),
+			ClientIP: h.ClientIP(c),
+			Logger:   loggerFor(c),
+		})
 		if err != nil {
-			return c.Status(http.StatusInternalServerError).JSON(JSONRPCResponse{
-				Success: false,
-				Data:    []string{err.Error()},
-			})
+			metrics.RPCCalls.WithLabelValues(body.Method, metrics.OutcomeFailure).Inc()
+
+			return sendErrorResponse(c, locale, err)
+		}
+
+		metrics.RPCCalls.WithLabelValues(body.Method, metrics.OutcomeSuccess).Inc()
+
+		for i := range data {
+			data[i] = locale.Translate(data[i])
 		}
 
-		return c.JSON(JSONRPCResponse{
+		return sendResponse(c, JSONRPCResponse{
 			Success: true,
 			Data:    data,
 		})
@@ -47,12 +260,20 @@ func (h *Handler) Handle(c *fiber.Ctx) error {
 
 	switch body.Method {
 	case "change-password":
-		return wrapRPC(h.changePassword)
+		if !h.opts.ChangePasswordEnabled {
+			return sendErrorResponse(c, locale, ErrFeatureDisabled)
+		}
+		if h.maintenance.Load() {
+			return sendErrorResponse(c, locale, ErrMaintenance)
+		}
+
+		return wrapRPC(h.changePasswordWithIP)
 
 	default:
-		return c.Status(http.StatusBadRequest).JSON(JSONRPCResponse{
+		return sendResponse(c.Status(http.StatusBadRequest), JSONRPCResponse{
 			Success: false,
-			Data:    []string{"method not found"},
+			Data:    []string{locale.Translate("method not found")},
+			Code:    CodeMethodNotFound,
 		})
 	}
 }
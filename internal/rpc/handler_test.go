@@ -0,0 +1,208 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+var errInvalidCredentials = ldapv3.NewError(ldapv3.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+
+type mockLDAP struct {
+	users   map[string]*ldap.User
+	changed []string
+	err     error
+	calls   int
+	// changeErrs are returned by the next password changes, before err.
+	changeErrs []error
+
+	expiry    time.Time
+	expiryErr error
+}
+
+func (m *mockLDAP) FindUserBySAMAccountName(sAMAccountName string) (*ldap.User, error) {
+	user, ok := m.users[sAMAccountName]
+	if !ok {
+		return nil, ldap.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (m *mockLDAP) CheckPasswordForSAMAccountName(sAMAccountName, _ string) (*ldap.User, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &ldap.User{SAMAccountName: sAMAccountName, Enabled: true}, nil
+}
+
+func (m *mockLDAP) ChangePasswordForSAMAccountName(sAMAccountName, _, _ string) error {
+	m.calls++
+
+	if len(m.changeErrs) > 0 {
+		err := m.changeErrs[0]
+		m.changeErrs = m.changeErrs[1:]
+
+		return err
+	}
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.changed = append(m.changed, sAMAccountName)
+
+	return nil
+}
+
+func (m *mockLDAP) PasswordExpiryForSAMAccountName(sAMAccountName string) (time.Time, error) {
+	if _, ok := m.users[sAMAccountName]; !ok {
+		return time.Time{}, ldap.ErrUserNotFound
+	}
+
+	return m.expiry, m.expiryErr
+}
+
+func newHandler(t *testing.T, opts *options.Opts, client rpc.LDAPClient) *rpc.Handler {
+	t.Helper()
+
+	h, err := rpc.NewWithClient(opts, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return h
+}
+
+func call(t *testing.T, h *rpc.Handler, body string) (int, rpc.JSONRPCResponse) {
+	t.Helper()
+
+	app := fiber.New()
+	app.Post("/api/rpc", h.Handle)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, _ := io.ReadAll(res.Body)
+
+	var parsed rpc.JSONRPCResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatalf("could not parse response %q: %v", raw, err)
+	}
+
+	return res.StatusCode, parsed
+}
+
+func changePasswordBody(params ...string) string {
+	raw, _ := json.Marshal(rpc.JSONRPC{Method: "change-password", Params: params})
+
+	return string(raw)
+}
+
+func TestHandleRejectsDuplicateKeys(t *testing.T) {
+	h := newHandler(t, &options.Opts{}, &mockLDAP{})
+
+	cases := []string{
+		`{"method":"change-password","method":"unknown","params":[]}`,
+		`{"method":"change-password","params":["a","b","c"],"params":[]}`,
+		`{"method":"change-password","Method":"unknown","params":[]}`,
+		// Keys match with Unicode simple folding, ſ folds to s and the Kelvin
+		// sign to k.
+		`{"method":"change-password","params":["a","b","c"],"param\u017f":[]}`,
+		`{"method":"change-password","params":[],"kelvin":1,"\u212aelvin":2}`,
+	}
+
+	for _, body := range cases {
+		status, res := call(t, h, body)
+
+		if status != http.StatusBadRequest {
+			t.Errorf("expected status %d for %s, got %d", http.StatusBadRequest, body, status)
+		}
+
+		if len(res.Data) != 1 || !strings.Contains(res.Data[0], "duplicate key") {
+			t.Errorf("expected duplicate key error for %s, got %v", body, res.Data)
+		}
+	}
+}
+
+func TestHandleUnknownMethod(t *testing.T) {
+	raw, _ := json.Marshal(rpc.JSONRPC{Method: "reset-password", Params: []string{"jdoe"}})
+
+	status, res := call(t, newHandler(t, defaultOpts(), &mockLDAP{}), string(raw))
+	if status != http.StatusBadRequest || res.Code != rpc.CodeMethodNotFound {
+		t.Errorf("expected 400 with code %q, got %d with %q", rpc.CodeMethodNotFound, status, res.Code)
+	}
+
+	status, res = call(t, newHandler(t, defaultOpts(), &mockLDAP{}), "[]")
+	if status != http.StatusBadRequest || res.Code != rpc.CodeInvalidRequest {
+		t.Errorf("expected 400 with code %q, got %d with %q", rpc.CodeInvalidRequest, status, res.Code)
+	}
+}
+
+func TestHandlePlainText(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/rpc", newHandler(t, defaultOpts(), &mockLDAP{}).Handle)
+
+	for _, c := range []struct {
+		Accept      string
+		ContentType string
+		Body        string
+	}{
+		{Accept: "", ContentType: fiber.MIMEApplicationJSON, Body: `{"success":false,"data":["the new password must be at least 8 characters long"],"code":"POLICY_VIOLATION"}`},
+		{Accept: "application/json", ContentType: fiber.MIMEApplicationJSON, Body: `{"success":false,"data":["the new password must be at least 8 characters long"],"code":"POLICY_VIOLATION"}`},
+		{Accept: "text/plain", ContentType: fiber.MIMETextPlainCharsetUTF8, Body: "the new password must be at least 8 characters long"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(changePasswordBody("jdoe", "Old-Passw0rd", "short")))
+		req.Header.Set("Content-Type", "application/json")
+		if c.Accept != "" {
+			req.Header.Set("Accept", c.Accept)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		raw, _ := io.ReadAll(res.Body)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("accept %q: expected status 400, got %d", c.Accept, res.StatusCode)
+		}
+		if contentType := res.Header.Get("Content-Type"); contentType != c.ContentType {
+			t.Errorf("accept %q: expected content type %q, got %q", c.Accept, c.ContentType, contentType)
+		}
+		if string(raw) != c.Body {
+			t.Errorf("accept %q: expected body %q, got %q", c.Accept, c.Body, raw)
+		}
+	}
+}
+
+func TestHandleChangePasswordDisabled(t *testing.T) {
+	opts := defaultOpts()
+	opts.ChangePasswordEnabled = false
+	client := &mockLDAP{}
+
+	status, res := call(t, newHandler(t, opts, client), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd!"))
+	if status != http.StatusForbidden || res.Code != rpc.CodeFeatureDisabled {
+		t.Errorf("expected 403 with code %q, got %d with %q", rpc.CodeFeatureDisabled, status, res.Code)
+	}
+	if client.calls != 0 {
+		t.Errorf("expected no password change, got %d", client.calls)
+	}
+}
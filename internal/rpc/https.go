@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hstsMaxAge is how long browsers should only use HTTPS for this host.
+const hstsMaxAge = "max-age=31536000"
+
+// isHTTPS reports whether the client connected with TLS, either to us or to a
+// trusted proxy that reported it in the X-Forwarded-Proto header.
+func (h *Handler) isHTTPS(c *fiber.Ctx) bool {
+	if c.Context().IsTLS() {
+		return true
+	}
+
+	peer, ok := peerAddr(c)
+	if !ok || !h.isTrustedProxy(peer) {
+		return false
+	}
+
+	proto, _, _ := strings.Cut(c.Get(fiber.HeaderXForwardedProto), ",")
+
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// RequireHTTPS redirects plain HTTP requests to HTTPS, so passwords are never
+// submitted in cleartext, and tells browsers to keep using HTTPS.
+func (h *Handler) RequireHTTPS(c *fiber.Ctx) error {
+	if !h.isHTTPS(c) {
+		// Neither the Host nor the X-Forwarded-Host header is used, clients
+		// could set them to any host and turn this into an open redirect.
+		return c.Redirect("https://"+h.opts.CanonicalHost+string(c.Request().URI().RequestURI()), http.StatusPermanentRedirect)
+	}
+
+	c.Set(fiber.HeaderStrictTransportSecurity, hstsMaxAge)
+
+	return c.Next()
+}
@@ -0,0 +1,65 @@
+package rpc_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	// Requests made with app.Test come from 0.0.0.0.
+	cases := []struct {
+		Name           string
+		TrustedProxies string
+		ForwardedProto string
+		Redirect       bool
+	}{
+		{Name: "plain request", Redirect: true},
+		{Name: "spoofed header from an untrusted peer", TrustedProxies: "10.0.0.0/8", ForwardedProto: "https", Redirect: true},
+		{Name: "trusted proxy without TLS", TrustedProxies: "0.0.0.0/32", ForwardedProto: "http", Redirect: true},
+		{Name: "trusted proxy with TLS", TrustedProxies: "0.0.0.0/32", ForwardedProto: "https"},
+		{Name: "trusted proxy chain with TLS", TrustedProxies: "0.0.0.0/32", ForwardedProto: "HTTPS, http"},
+	}
+
+	for _, c := range cases {
+		prefixes, err := options.ParseTrustedProxies(c.TrustedProxies)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		opts := defaultOpts()
+		opts.TrustedProxies = prefixes
+		opts.CanonicalHost = "passwd.example.com"
+
+		app := fiber.New()
+		app.Use(newHandler(t, opts, &mockLDAP{}).RequireHTTPS)
+		app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+		req := httptest.NewRequest(http.MethodGet, "http://evil.example.com/?lang=de", nil)
+		req.Header.Set("X-Forwarded-Host", "evil.example.com")
+		if c.ForwardedProto != "" {
+			req.Header.Set("X-Forwarded-Proto", c.ForwardedProto)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !c.Redirect {
+			if res.StatusCode != http.StatusNoContent || res.Header.Get("Strict-Transport-Security") == "" {
+				t.Errorf("%s: expected the request to pass with HSTS, got %d with %q", c.Name, res.StatusCode, res.Header.Get("Strict-Transport-Security"))
+			}
+
+			continue
+		}
+
+		expected := "https://passwd.example.com/?lang=de"
+		if res.StatusCode != http.StatusPermanentRedirect || res.Header.Get("Location") != expected {
+			t.Errorf("%s: expected a redirect to %s, got %d to %q", c.Name, expected, res.StatusCode, res.Header.Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestHandleLocalizesMessages(t *testing.T) {
+	h := newHandler(t, defaultOpts(), &mockLDAP{})
+
+	app := fiber.New()
+	app.Post("/api/rpc", h.Handle)
+
+	cases := []struct {
+		Name     string
+		URL      string
+		Header   string
+		Params   []string
+		Expected string
+	}{
+		{Name: "accept-language", URL: "/api/rpc", Header: "de-DE,de;q=0.9", Params: []string{"jdoe", "Old-Passw0rd", "short"}, Expected: "das neue Passwort muss mindestens 8 Zeichen lang sein"},
+		{Name: "query", URL: "/api/rpc?lang=de", Header: "en", Params: []string{"jdoe", "Old-Passw0rd", "New-Passw0rd"}, Expected: "Passwort erfolgreich geändert"},
+		{Name: "unknown", URL: "/api/rpc?lang=fr", Params: []string{"jdoe", "Old-Passw0rd", "short"}, Expected: "the new password must be at least 8 characters long"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, c.URL, strings.NewReader(changePasswordBody(c.Params...)))
+		req.Header.Set("Content-Type", "application/json")
+		if c.Header != "" {
+			req.Header.Set("Accept-Language", c.Header)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.Name, err)
+		}
+
+		var parsed rpc.JSONRPCResponse
+		if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+			t.Fatalf("%s: could not decode response: %v", c.Name, err)
+		}
+		if len(parsed.Data) == 0 || parsed.Data[0] != c.Expected {
+			t.Errorf("%s: expected %q, got %v", c.Name, c.Expected, parsed.Data)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package rpc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandleCountsRPCCalls(t *testing.T) {
+	success := metrics.RPCCalls.WithLabelValues("change-password", metrics.OutcomeSuccess)
+	failure := metrics.RPCCalls.WithLabelValues("change-password", metrics.OutcomeFailure)
+	successBefore, failureBefore := testutil.ToFloat64(success), testutil.ToFloat64(failure)
+
+	call(t, newHandler(t, defaultOpts(), &mockLDAP{}), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+	call(t, newHandler(t, defaultOpts(), &mockLDAP{err: errors.New("invalid credentials")}), changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd"))
+
+	if actual := testutil.ToFloat64(success) - successBefore; actual != 1 {
+		t.Errorf("expected 1 successful call, got %v", actual)
+	}
+	if actual := testutil.ToFloat64(failure) - failureBefore; actual != 1 {
+		t.Errorf("expected 1 failed call, got %v", actual)
+	}
+}
@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenAPIDocument is the subset of an OpenAPI 3 document needed to describe
+// our API.
+type OpenAPIDocument struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       OpenAPIInfo          `json:"info"`
+	Servers    []OpenAPIServer      `json:"servers"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components OpenAPIComponents    `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+}
+
+type Operation struct {
+	Summary     string               `json:"summary"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+}
+
+// schemaOf derives the schema of a type from its JSON encoding, so the
+// document can't drift from the structs we actually send and receive.
+func schemaOf(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, flags, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			schema.Properties[name] = schemaOf(field.Type)
+			if !strings.Contains(flags, "omitempty") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+
+		return schema
+	default:
+		return &Schema{}
+	}
+}
+
+func schemaFor[T any]() *Schema {
+	return schemaOf(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{fiber.MIMEApplicationJSON: {Schema: schema}}
+}
+
+func jsonResponse(description, schema string) *Response {
+	return &Response{Description: description, Content: jsonContent(ref(schema))}
+}
+
+// openAPIDocument describes the endpoints that are served with the options of
+// the handler.
+func (h *Handler) openAPIDocument() OpenAPIDocument {
+	rpcSchema := schemaFor[JSONRPCResponse]()
+	rpcSchema.Properties["code"].Enum = []string{
+		CodeInvalidRequest,
+		CodeMethodNotFound,
+		CodeFeatureDisabled,
+		CodeMaintenance,
+		CodeInvalidArgument,
+		CodePolicyViolation,
+		CodeRateLimited,
+		CodeTooManyAttempts,
+		CodePasswordTooYoung,
+		CodeAccountNotChangeable,
+		CodePasswordNotChanged,
+		CodeInvalidCredentials,
+		CodeLDAPError,
+	}
+
+	requestSchema := schemaFor[JSONRPC]()
+	requestSchema.Properties["method"].Enum = []string{"change-password"}
+
+	expirySchema := schemaFor[PasswordExpiryResponse]()
+	expirySchema.Properties["status"].Enum = []string{ExpiryStatusExpires, ExpiryStatusNever, ExpiryStatusUnknown}
+
+	rpcResponses := map[string]*Response{
+		strconv.Itoa(http.StatusOK): jsonResponse("The call succeeded.", "JSONRPCResponse"),
+	}
+	for _, status := range []int{
+		http.StatusBadRequest,
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusServiceUnavailable,
+	} {
+		rpcResponses[strconv.Itoa(status)] = jsonResponse("The call failed, see the code for the reason.", "JSONRPCResponse")
+	}
+
+	// The paths are relative to the server, which includes the base path.
+	server := h.opts.BasePath
+	if server == "" {
+		server = "/"
+	}
+
+	doc := OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:   h.opts.AppName,
+			Version: "1",
+		},
+		Servers: []OpenAPIServer{{URL: server}},
+		Paths: map[string]*PathItem{
+			"/api/rpc": {Post: &Operation{
+				Summary: "Calls a method, the params of change-password are the username, the current and the new password.",
+				RequestBody: &RequestBody{
+					Required: true,
+					Content:  jsonContent(ref("JSONRPC")),
+				},
+				Responses: rpcResponses,
+			}},
+			"/api/policy": {Get: &Operation{
+				Summary: "Returns the password policy.",
+				Responses: map[string]*Response{
+					strconv.Itoa(http.StatusOK): jsonResponse("The password policy.", "PasswordPolicy"),
+				},
+			}},
+			"/api/v1/ui-config": {Get: &Operation{
+				Summary: "Returns the configuration the frontend needs to render itself.",
+				Responses: map[string]*Response{
+					strconv.Itoa(http.StatusOK): jsonResponse("The frontend configuration.", "UIConfig"),
+				},
+			}},
+		},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*Schema{
+				"JSONRPC":         requestSchema,
+				"JSONRPCResponse": rpcSchema,
+				"PasswordPolicy":  schemaFor[PasswordPolicy](),
+				"UIConfig":        schemaFor[UIConfig](),
+			},
+		},
+	}
+
+	if h.opts.ChangePasswordEnabled {
+		doc.Paths["/api/validate-password"] = &PathItem{Post: &Operation{
+			Summary: "Checks a password against the password policy without changing anything.",
+			RequestBody: &RequestBody{
+				Required: true,
+				Content:  jsonContent(ref("ValidatePasswordRequest")),
+			},
+			Responses: map[string]*Response{
+				strconv.Itoa(http.StatusOK):         jsonResponse("The result of the validation.", "ValidatePasswordResponse"),
+				strconv.Itoa(http.StatusBadRequest): jsonResponse("The request body could not be parsed.", "ValidatePasswordResponse"),
+			},
+		}}
+		doc.Components.Schemas["ValidatePasswordRequest"] = schemaFor[ValidatePasswordRequest]()
+		doc.Components.Schemas["ValidatePasswordResponse"] = schemaFor[ValidatePasswordResponse]()
+	}
+
+	if h.opts.PasswordExpiryEnabled {
+		doc.Paths["/api/password-expiry"] = &PathItem{Get: &Operation{
+			Summary: "Returns how many days are left until the password of a user expires.",
+			Parameters: []Parameter{
+				{Name: "username", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+			},
+			Responses: map[string]*Response{
+				strconv.Itoa(http.StatusOK):                  jsonResponse("The expiry of the password.", "PasswordExpiryResponse"),
+				strconv.Itoa(http.StatusBadRequest):          jsonResponse("The username is missing.", "JSONRPCResponse"),
+				strconv.Itoa(http.StatusInternalServerError): jsonResponse("The directory could not be queried.", "JSONRPCResponse"),
+			},
+		}}
+		doc.Components.Schemas["PasswordExpiryResponse"] = expirySchema
+	}
+
+	return doc
+}
+
+// OpenAPI serves an OpenAPI 3 description of the public API for integrators.
+func (h *Handler) OpenAPI(c *fiber.Ctx) error {
+	return c.JSON(h.openAPIDocument())
+}
@@ -0,0 +1,86 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+func fetchOpenAPI(t *testing.T, opts *options.Opts) map[string]any {
+	t.Helper()
+
+	app := fiber.New()
+	app.Get("/api/openapi.json", newHandler(t, opts, &mockLDAP{}).OpenAPI)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatalf("could not decode document: %v", err)
+	}
+
+	return doc
+}
+
+func TestOpenAPI(t *testing.T) {
+	opts := defaultOpts()
+	opts.PasswordExpiryEnabled = true
+
+	doc := fetchOpenAPI(t, opts)
+
+	if doc["openapi"] != "3.0.3" {
+		t.Errorf("expected an OpenAPI 3 document, got version %v", doc["openapi"])
+	}
+
+	paths, _ := doc["paths"].(map[string]any)
+	for _, path := range []string{"/api/rpc", "/api/policy", "/api/v1/ui-config", "/api/validate-password", "/api/password-expiry"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("expected path %s to be described", path)
+		}
+	}
+
+	schemas, _ := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	response, _ := schemas["JSONRPCResponse"].(map[string]any)
+	properties, _ := response["properties"].(map[string]any)
+	for _, property := range []string{"success", "data", "code"} {
+		if _, ok := properties[property]; !ok {
+			t.Errorf("expected JSONRPCResponse to have property %s, got %v", property, properties)
+		}
+	}
+}
+
+func TestOpenAPIOmitsDisabledEndpoints(t *testing.T) {
+	opts := defaultOpts()
+	opts.ChangePasswordEnabled = false
+
+	paths, _ := fetchOpenAPI(t, opts)["paths"].(map[string]any)
+	for _, path := range []string{"/api/validate-password", "/api/password-expiry"} {
+		if _, ok := paths[path]; ok {
+			t.Errorf("expected disabled path %s not to be described", path)
+		}
+	}
+}
+
+func TestOpenAPIServers(t *testing.T) {
+	cases := map[string]string{
+		"":         "/",
+		"/pwreset": "/pwreset",
+	}
+
+	for basePath, expected := range cases {
+		opts := defaultOpts()
+		opts.BasePath = basePath
+
+		servers, _ := fetchOpenAPI(t, opts)["servers"].([]any)
+		if len(servers) != 1 || servers[0].(map[string]any)["url"] != expected {
+			t.Errorf("expected the server %s for the base path %q, got %v", expected, basePath, servers)
+		}
+	}
+}
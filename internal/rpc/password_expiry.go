@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+const (
+	ExpiryStatusExpires = "expires"
+	ExpiryStatusNever   = "never"
+	ExpiryStatusUnknown = "unknown"
+)
+
+type PasswordExpiryResponse struct {
+	// Status is one of ExpiryStatusExpires, ExpiryStatusNever and
+	// ExpiryStatusUnknown.
+	Status string `json:"status"`
+	// DaysRemaining is only set if the password expires. It is negative once
+	// the password expired.
+	DaysRemaining *int `json:"daysRemaining,omitempty"`
+}
+
+// PasswordExpiry tells how many days are left until the password of a user
+// expires, so the frontend can ask users to change it in time. Unknown users
+// are answered like directories that don't expose the expiry.
+func (h *Handler) PasswordExpiry(c *fiber.Ctx) error {
+	username := c.Query("username")
+	if username == "" {
+		return sendErrorResponse(c, localeFor(c), ErrEmptyUsername)
+	}
+
+	expiry, err := h.ldap.PasswordExpiryForSAMAccountName(username)
+	switch {
+	case errors.Is(err, ErrPasswordNeverExpires):
+		return c.JSON(PasswordExpiryResponse{Status: ExpiryStatusNever})
+	case errors.Is(err, ErrPasswordExpiryUnknown), errors.Is(err, ldap.ErrUserNotFound):
+		return c.JSON(PasswordExpiryResponse{Status: ExpiryStatusUnknown})
+	case err != nil:
+		return sendErrorResponse(c, localeFor(c), err)
+	}
+
+	days := int(math.Floor(time.Until(expiry).Hours() / 24))
+
+	return c.JSON(PasswordExpiryResponse{
+		Status:        ExpiryStatusExpires,
+		DaysRemaining: &days,
+	})
+}
@@ -0,0 +1,82 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+	ldap "github.com/netresearch/simple-ldap-go"
+)
+
+func passwordExpiry(t *testing.T, client *mockLDAP, username string) (int, rpc.PasswordExpiryResponse) {
+	t.Helper()
+
+	app := fiber.New()
+	app.Get("/api/password-expiry", newHandler(t, defaultOpts(), client).PasswordExpiry)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/password-expiry?username="+username, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var body rpc.PasswordExpiryResponse
+	_ = json.NewDecoder(res.Body).Decode(&body)
+
+	return res.StatusCode, body
+}
+
+func TestPasswordExpiry(t *testing.T) {
+	users := map[string]*ldap.User{"jdoe": {Enabled: true}}
+
+	cases := []struct {
+		Name          string
+		Username      string
+		Expiry        time.Time
+		ExpiryErr     error
+		Status        string
+		DaysRemaining int
+	}{
+		{Name: "expires soon", Username: "jdoe", Expiry: time.Now().Add(3*24*time.Hour + time.Hour), Status: rpc.ExpiryStatusExpires, DaysRemaining: 3},
+		{Name: "expires today", Username: "jdoe", Expiry: time.Now().Add(time.Hour), Status: rpc.ExpiryStatusExpires, DaysRemaining: 0},
+		{Name: "expired", Username: "jdoe", Expiry: time.Now().Add(-36 * time.Hour), Status: rpc.ExpiryStatusExpires, DaysRemaining: -2},
+		{Name: "never expires", Username: "jdoe", ExpiryErr: rpc.ErrPasswordNeverExpires, Status: rpc.ExpiryStatusNever},
+		{Name: "not exposed", Username: "jdoe", ExpiryErr: rpc.ErrPasswordExpiryUnknown, Status: rpc.ExpiryStatusUnknown},
+		{Name: "unknown user", Username: "nobody", Status: rpc.ExpiryStatusUnknown},
+	}
+
+	for _, c := range cases {
+		status, res := passwordExpiry(t, &mockLDAP{users: users, expiry: c.Expiry, expiryErr: c.ExpiryErr}, c.Username)
+		if status != http.StatusOK || res.Status != c.Status {
+			t.Errorf("%s: expected status %q, got %d with %q", c.Name, c.Status, status, res.Status)
+			continue
+		}
+
+		if c.Status != rpc.ExpiryStatusExpires {
+			if res.DaysRemaining != nil {
+				t.Errorf("%s: expected no days remaining, got %d", c.Name, *res.DaysRemaining)
+			}
+
+			continue
+		}
+
+		if res.DaysRemaining == nil || *res.DaysRemaining != c.DaysRemaining {
+			t.Errorf("%s: expected %d days remaining, got %v", c.Name, c.DaysRemaining, res.DaysRemaining)
+		}
+	}
+}
+
+func TestPasswordExpiryErrors(t *testing.T) {
+	if status, _ := passwordExpiry(t, &mockLDAP{}, ""); status != http.StatusBadRequest {
+		t.Errorf("expected status 400 without a username, got %d", status)
+	}
+
+	client := &mockLDAP{users: map[string]*ldap.User{"jdoe": {}}, expiryErr: errors.New("connection reset")}
+	if status, _ := passwordExpiry(t, client, "jdoe"); status != http.StatusInternalServerError {
+		t.Errorf("expected status 500 when the directory fails, got %d", status)
+	}
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"net/netip"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/i18n"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/metrics"
+)
+
+var ErrRateLimited error = i18n.Errorf("too many requests, try again later")
+
+// RateLimitReached answers requests denied by a rate limiter. The limiter
+// already set the Retry-After header, so clients know when to try again.
+func RateLimitReached(c *fiber.Ctx) error {
+	metrics.RateLimited.WithLabelValues(c.Route().Path).Inc()
+
+	return sendErrorResponse(c, localeFor(c), ErrRateLimited)
+}
+
+// RateLimitExempt tells whether the client of a request is in one of the
+// ranges that are exempt from the IP rate limits. It's meant as the Next
+// function of the limiters.
+func (h *Handler) RateLimitExempt(c *fiber.Ctx) bool {
+	client, err := netip.ParseAddr(h.ClientIP(c))
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range h.opts.RateLimitExemptCIDRs {
+		if prefix.Contains(client) {
+			return true
+		}
+	}
+
+	return false
+}
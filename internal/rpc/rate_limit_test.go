@@ -0,0 +1,93 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestRateLimitedChangePassword(t *testing.T) {
+	h := newHandler(t, defaultOpts(), &mockLDAP{})
+
+	app := fiber.New()
+	app.Post("/api/rpc", limiter.New(limiter.Config{
+		Max:               1,
+		Expiration:        time.Minute,
+		LimiterMiddleware: limiter.SlidingWindow{},
+		LimitReached:      rpc.RateLimitReached,
+	}), h.Handle)
+
+	var res *http.Response
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd")))
+		req.Header.Set("Content-Type", "application/json")
+
+		var err error
+		if res, err = app.Test(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if res.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", res.StatusCode)
+	}
+	if res.Header.Get(fiber.HeaderRetryAfter) == "" {
+		t.Error("expected Retry-After header to be set")
+	}
+
+	var parsed rpc.JSONRPCResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if parsed.Success || len(parsed.Data) != 1 || parsed.Data[0] != rpc.ErrRateLimited.Error() || parsed.Code != rpc.CodeRateLimited {
+		t.Errorf("unexpected response: %+v", parsed)
+	}
+}
+
+func TestRateLimitExempt(t *testing.T) {
+	cases := map[string]int{
+		"":                   fiber.StatusTooManyRequests,
+		"10.0.0.0/8":         fiber.StatusTooManyRequests,
+		"0.0.0.0/32":         fiber.StatusOK,
+		"10.0.0.0/8,0.0.0.0": fiber.StatusOK,
+	}
+
+	for exempt, expected := range cases {
+		prefixes, err := options.ParseTrustedProxies(exempt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		opts := defaultOpts()
+		opts.RateLimitExemptCIDRs = prefixes
+		h := newHandler(t, opts, &mockLDAP{})
+
+		app := fiber.New()
+		app.Get("/", limiter.New(limiter.Config{
+			Max:          1,
+			Expiration:   time.Minute,
+			Next:         h.RateLimitExempt,
+			KeyGenerator: h.ClientIP,
+			LimitReached: rpc.RateLimitReached,
+		}), func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+		var res *http.Response
+		for i := 0; i < 3; i++ {
+			if res, err = app.Test(httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if res.StatusCode != expected {
+			t.Errorf("exempt %q: expected status %d, got %d", exempt, expected, res.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+// PolicyOpts returns the options with the password policy that is currently
+// in effect.
+func (h *Handler) PolicyOpts() *options.Opts {
+	return h.policy.Load()
+}
+
+// ReloadPolicy reads the password policy again from the config file and swaps
+// it in, so it can be changed without a restart. Options set in the
+// environment or as flags can't be changed this way.
+// Requests that are already running keep the policy they started with.
+func (h *Handler) ReloadPolicy(c *fiber.Ctx) error {
+	opts, err := h.policy.Load().ReloadPolicy()
+	if err != nil {
+		loggerFor(c).Error("could not reload the password policy", "err", err)
+
+		messages := []string{err.Error()}
+		var configErr *options.ConfigError
+		if errors.As(err, &configErr) {
+			messages = configErr.Problems
+		}
+
+		return sendResponse(c.Status(http.StatusInternalServerError), JSONRPCResponse{
+			Success: false,
+			Data:    messages,
+			Code:    CodeInvalidConfig,
+		})
+	}
+
+	h.policy.Store(opts)
+	loggerFor(c).Info("reloaded the password policy", "policy", PolicySummary(opts))
+
+	return c.JSON(PolicyFromOpts(opts))
+}
@@ -0,0 +1,93 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestReloadPolicy(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+
+	opts := defaultOpts()
+	opts.AdminToken = "s3cret"
+	client := &mockLDAP{}
+	h := newHandler(t, opts, client)
+
+	app := fiber.New()
+	app.Post("/admin/reload-policy", h.RequireAdminToken, h.ReloadPolicy)
+	app.Post("/api/validate-password", h.ValidatePassword)
+
+	reload := func() (int, []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload-policy", nil)
+		req.Header.Set(rpc.AdminTokenHeader, "s3cret")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var raw json.RawMessage
+		if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		return res.StatusCode, raw
+	}
+
+	valid := func(password string) bool {
+		raw, _ := json.Marshal(rpc.ValidatePasswordRequest{Username: "jdoe", Password: password})
+		req := httptest.NewRequest(http.MethodPost, "/api/validate-password", strings.NewReader(string(raw)))
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var parsed rpc.ValidatePasswordResponse
+		if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		return parsed.Valid
+	}
+
+	if !valid("Tr0ub4dor&3x") {
+		t.Fatal("expected the password to be valid before the reload")
+	}
+
+	t.Setenv("MIN_LENGTH", "16")
+
+	status, raw := reload()
+	var policy rpc.PasswordPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil || status != http.StatusOK || policy.MinLength != 16 {
+		t.Fatalf("expected the reloaded policy, got %d with %s", status, raw)
+	}
+
+	if valid("Tr0ub4dor&3x") {
+		t.Error("expected the password to be too short after the reload")
+	}
+	if status, res := call(t, h, changePasswordBody("jdoe", "Old-Passw0rd", "Tr0ub4dor&3x")); status != http.StatusBadRequest || res.Code != rpc.CodePolicyViolation {
+		t.Errorf("expected the change to violate the reloaded policy, got %d with %q", status, res.Code)
+	}
+
+	t.Setenv("MIN_LENGTH", "many")
+
+	status, raw = reload()
+	var res rpc.JSONRPCResponse
+	if err := json.Unmarshal(raw, &res); err != nil || status != http.StatusInternalServerError || res.Code != rpc.CodeInvalidConfig {
+		t.Errorf("expected an invalid config to be rejected, got %d with %s", status, raw)
+	}
+	if h.PolicyOpts().MinLength != 16 {
+		t.Errorf("expected the previous policy to stay in effect, got a minimum length of %d", h.PolicyOpts().MinLength)
+	}
+	if len(client.changed) != 0 {
+		t.Errorf("expected no password change, got %v", client.changed)
+	}
+}
@@ -0,0 +1,40 @@
+package rpc
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// validRequestID matches the request IDs that are accepted from clients. Others
+// could forge log lines or bloat every log message of the request.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID assigns every request a random ID, or keeps a valid one sent in
+// the X-Request-ID header, and echoes it in the response.
+func RequestID() fiber.Handler {
+	assign := requestid.New(requestid.Config{
+		Generator: utils.UUIDv4,
+	})
+
+	return func(c *fiber.Ctx) error {
+		if id := c.Get(fiber.HeaderXRequestID); id != "" && !validRequestID.MatchString(id) {
+			c.Request().Header.Del(fiber.HeaderXRequestID)
+		}
+
+		return assign(c)
+	}
+}
+
+func requestIDFor(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+	return id
+}
+
+// loggerFor returns a logger that adds the ID of the request to every message.
+func loggerFor(c *fiber.Ctx) *slog.Logger {
+	return slog.Default().With("request_id", requestIDFor(c))
+}
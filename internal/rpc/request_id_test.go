@@ -0,0 +1,91 @@
+package rpc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestRequestID(t *testing.T) {
+	app := fiber.New()
+	app.Use(rpc.RequestID())
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Header.Get(fiber.HeaderXRequestID) == "" {
+		t.Error("expected a generated request ID")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "support-ticket-42")
+
+	res, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id := res.Header.Get(fiber.HeaderXRequestID); id != "support-ticket-42" {
+		t.Errorf("expected the provided request ID to be kept, got %q", id)
+	}
+}
+
+func TestRequestIDReplacesInvalidIDs(t *testing.T) {
+	app := fiber.New()
+	app.Use(rpc.RequestID())
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+	for _, invalid := range []string{"id with spaces", "id\" msg=\"forged", "ticket/42", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderXRequestID, invalid)
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id := res.Header.Get(fiber.HeaderXRequestID); id == "" || id == invalid {
+			t.Errorf("expected %q to be replaced by a generated request ID, got %q", invalid, id)
+		}
+	}
+}
+
+func TestRequestIDInLogs(t *testing.T) {
+	var out bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	opts := defaultOpts()
+	opts.EnumerationResistance = true
+
+	app := fiber.New()
+	app.Use(rpc.RequestID())
+	app.Post("/api/rpc", newHandler(t, opts, &mockLDAP{err: errors.New("connection reset")}).Handle)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd!")))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderXRequestID, "support-ticket-42")
+
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var line struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("could not parse log line %q: %v", out.String(), err)
+	}
+	if line.RequestID != "support-ticket-42" {
+		t.Errorf("expected the request ID in the log line, got %q", out.String())
+	}
+}
@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	ldap "github.com/netresearch/simple-ldap-go"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminTokenHeader is the header that has to carry Opts.AdminToken on
+// requests to the admin endpoints.
+const AdminTokenHeader = "X-Admin-Token"
+
+type Status struct {
+	UptimeSeconds int64 `json:"uptimeSeconds"`
+	// LockoutTrackedAccounts is the amount of accounts with recent failed
+	// password changes, it's always 0 when the lockout is disabled.
+	LockoutTrackedAccounts int `json:"lockoutTrackedAccounts"`
+	// CooldownTrackedAccounts is the amount of accounts whose password was
+	// changed within the minimum password age, it's always 0 when that is
+	// disabled.
+	CooldownTrackedAccounts int  `json:"cooldownTrackedAccounts"`
+	Maintenance             bool `json:"maintenance"`
+}
+
+type Maintenance struct {
+	Enabled bool `json:"enabled"`
+}
+
+type UserExists struct {
+	Exists bool `json:"exists"`
+}
+
+// authAdmin tells whether a request carries the admin token. A configured
+// hash takes precedence over the plain text token, which is compared in
+// constant time.
+func (h *Handler) authAdmin(c *fiber.Ctx) bool {
+	token := c.Get(AdminTokenHeader)
+	if token == "" {
+		return false
+	}
+
+	if h.opts.AdminTokenHash != "" {
+		return bcrypt.CompareHashAndPassword([]byte(h.opts.AdminTokenHash), []byte(token)) == nil
+	}
+
+	return h.opts.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.AdminToken)) == 1
+}
+
+// RequireAdminToken rejects requests that don't carry the configured admin
+// token. Without a configured token every request is rejected.
+func (h *Handler) RequireAdminToken(c *fiber.Ctx) error {
+	if !h.authAdmin(c) {
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	return c.Next()
+}
+
+func (h *Handler) status() Status {
+	status := Status{
+		UptimeSeconds: int64(time.Since(h.started) / time.Second),
+		Maintenance:   h.maintenance.Load(),
+	}
+	if h.lockout != nil {
+		status.LockoutTrackedAccounts = h.lockout.Count()
+	}
+	if h.cooldown != nil {
+		status.CooldownTrackedAccounts = h.cooldown.Count()
+	}
+
+	return status
+}
+
+// Status reports the state of the in-memory stores, so operators can see how
+// much they hold before it becomes a problem.
+func (h *Handler) Status(c *fiber.Ctx) error {
+	return c.JSON(h.status())
+}
+
+// startStatsReporter periodically logs the state of the in-memory stores, for
+// environments that only collect logs, until the returned function is called.
+func (h *Handler) startStatsReporter(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				status := h.status()
+				slog.Info("store occupancy",
+					"lockout_tracked_accounts", status.LockoutTrackedAccounts,
+					"cooldown_tracked_accounts", status.CooldownTrackedAccounts,
+				)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// UserExists tells whether an account exists. Only admins may ask, so unlike
+// the public endpoints it can answer truthfully.
+func (h *Handler) UserExists(c *fiber.Ctx) error {
+	username := c.Query("username")
+	if username == "" {
+		return sendErrorResponse(c, localeFor(c), ErrEmptyUsername)
+	}
+
+	_, err := h.ldap.FindUserBySAMAccountName(username)
+	if errors.Is(err, ldap.ErrUserNotFound) {
+		return c.JSON(UserExists{Exists: false})
+	}
+	if err != nil {
+		return sendErrorResponse(c, localeFor(c), err)
+	}
+
+	return c.JSON(UserExists{Exists: true})
+}
+
+// SetMaintenance switches the maintenance mode on or off without a restart.
+func (h *Handler) SetMaintenance(c *fiber.Ctx) error {
+	var body Maintenance
+	if err := c.BodyParser(&body); err != nil {
+		return sendResponse(c.Status(http.StatusBadRequest), JSONRPCResponse{
+			Success: false,
+			Data:    []string{err.Error()},
+			Code:    CodeInvalidRequest,
+		})
+	}
+
+	h.maintenance.Store(body.Enabled)
+	loggerFor(c).Info("changed maintenance mode", "enabled", body.Enabled)
+
+	return c.JSON(body)
+}
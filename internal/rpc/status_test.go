@@ -0,0 +1,275 @@
+package rpc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+	ldap "github.com/netresearch/simple-ldap-go"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestStatusRequiresAdminToken(t *testing.T) {
+	opts := defaultOpts()
+	opts.AdminToken = "s3cret"
+	opts.LockoutThreshold = 3
+	opts.LockoutDuration = time.Hour
+
+	client := &mockLDAP{err: errInvalidCredentials}
+	h := newHandler(t, opts, client)
+	defer h.Close()
+
+	call(t, h, changePasswordBody("jdoe", "Wrong-Passw0rd", "New-Passw0rd"))
+
+	app := fiber.New()
+	app.Get("/admin/status", h.RequireAdminToken, h.Status)
+
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		if token != "" {
+			req.Header.Set(rpc.AdminTokenHeader, token)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("expected status 401 for token %q, got %d", token, res.StatusCode)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set(rpc.AdminTokenHeader, "s3cret")
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var status rpc.Status
+	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if status.LockoutTrackedAccounts != 1 {
+		t.Errorf("expected 1 tracked account, got %d", status.LockoutTrackedAccounts)
+	}
+}
+
+func TestUserExists(t *testing.T) {
+	opts := defaultOpts()
+	opts.AdminToken = "s3cret"
+
+	app := fiber.New()
+	h := newHandler(t, opts, &mockLDAP{users: map[string]*ldap.User{"jdoe": {Enabled: true}}})
+	app.Get("/admin/user-exists", h.RequireAdminToken, h.UserExists)
+
+	cases := []struct {
+		Token    string
+		Username string
+		Status   int
+		Exists   bool
+	}{
+		{Token: "", Username: "jdoe", Status: fiber.StatusUnauthorized},
+		{Token: "wrong", Username: "jdoe", Status: fiber.StatusUnauthorized},
+		{Token: "s3cret", Username: "jdoe", Status: fiber.StatusOK, Exists: true},
+		{Token: "s3cret", Username: "nobody", Status: fiber.StatusOK, Exists: false},
+		{Token: "s3cret", Username: "", Status: fiber.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/user-exists?username="+c.Username, nil)
+		if c.Token != "" {
+			req.Header.Set(rpc.AdminTokenHeader, c.Token)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.StatusCode != c.Status {
+			t.Errorf("expected status %d for %q with token %q, got %d", c.Status, c.Username, c.Token, res.StatusCode)
+			continue
+		}
+		if c.Status != fiber.StatusOK {
+			continue
+		}
+
+		var body rpc.UserExists
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+		if body.Exists != c.Exists {
+			t.Errorf("expected exists to be %v for %q, got %v", c.Exists, c.Username, body.Exists)
+		}
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	opts := defaultOpts()
+	opts.AdminToken = "s3cret"
+	client := &mockLDAP{}
+	h := newHandler(t, opts, client)
+
+	app := fiber.New()
+	app.Get("/api/policy", h.Policy)
+	app.Post("/api/rpc", h.Handle)
+	app.Post("/admin/maintenance", h.RequireAdminToken, h.SetMaintenance)
+
+	setMaintenance := func(enabled bool) {
+		t.Helper()
+
+		body, _ := json.Marshal(rpc.Maintenance{Enabled: enabled})
+		req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(rpc.AdminTokenHeader, "s3cret")
+
+		res, err := app.Test(req)
+		if err != nil || res.StatusCode != fiber.StatusOK {
+			t.Fatalf("could not set maintenance mode: %v", err)
+		}
+	}
+
+	changePassword := func() rpc.JSONRPCResponse {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/rpc", strings.NewReader(changePasswordBody("jdoe", "Old-Passw0rd", "New-Passw0rd!")))
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var body rpc.JSONRPCResponse
+		_ = json.NewDecoder(res.Body).Decode(&body)
+		if (res.StatusCode == fiber.StatusServiceUnavailable) != (body.Code == rpc.CodeMaintenance) {
+			t.Errorf("expected status 503 exactly with code %q, got %d with %q", rpc.CodeMaintenance, res.StatusCode, body.Code)
+		}
+
+		return body
+	}
+
+	setMaintenance(true)
+
+	if res := changePassword(); res.Code != rpc.CodeMaintenance {
+		t.Errorf("expected password changes to be rejected, got %+v", res)
+	}
+	if client.calls != 0 {
+		t.Errorf("expected no password change, got %d", client.calls)
+	}
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/policy", nil))
+	if err != nil || res.StatusCode != fiber.StatusOK {
+		t.Errorf("expected the policy to be served during maintenance, got %v", err)
+	}
+
+	setMaintenance(false)
+
+	if res := changePassword(); !res.Success {
+		t.Errorf("expected password changes to work again, got %+v", res)
+	}
+}
+
+// syncBuffer is a buffer that log lines can be written to from several
+// goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestStatsReporter(t *testing.T) {
+	var out syncBuffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	opts := defaultOpts()
+	opts.LockoutThreshold = 3
+	opts.LockoutDuration = time.Hour
+	opts.StatsInterval = 10 * time.Millisecond
+
+	h := newHandler(t, opts, &mockLDAP{err: errInvalidCredentials})
+	call(t, h, changePasswordBody("jdoe", "Wrong-Passw0rd", "New-Passw0rd!"))
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String(), "store occupancy") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	h.Close()
+
+	if !strings.Contains(out.String(), "msg=\"store occupancy\" lockout_tracked_accounts=1 cooldown_tracked_accounts=0") {
+		t.Errorf("expected the occupancy to be logged, got %q", out.String())
+	}
+}
+
+func TestRequireAdminTokenHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		Name     string
+		Token    string
+		Hash     string
+		Header   string
+		Expected int
+	}{
+		{Name: "hash, correct token", Hash: string(hash), Header: "s3cret", Expected: fiber.StatusOK},
+		{Name: "hash, wrong token", Hash: string(hash), Header: "wrong", Expected: fiber.StatusUnauthorized},
+		{Name: "hash, no token", Hash: string(hash), Expected: fiber.StatusUnauthorized},
+		{Name: "hash takes precedence", Token: "plain", Hash: string(hash), Header: "plain", Expected: fiber.StatusUnauthorized},
+		{Name: "plain text, correct token", Token: "s3cret", Header: "s3cret", Expected: fiber.StatusOK},
+		{Name: "plain text, wrong token", Token: "s3cret", Header: "s3cre", Expected: fiber.StatusUnauthorized},
+		{Name: "nothing configured", Header: "", Expected: fiber.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		opts := defaultOpts()
+		opts.AdminToken = c.Token
+		opts.AdminTokenHash = c.Hash
+		h := newHandler(t, opts, &mockLDAP{})
+
+		app := fiber.New()
+		app.Get("/admin/status", h.RequireAdminToken, h.Status)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		if c.Header != "" {
+			req.Header.Set(rpc.AdminTokenHeader, c.Header)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.StatusCode != c.Expected {
+			t.Errorf("%s: expected status %d, got %d", c.Name, c.Expected, res.StatusCode)
+		}
+	}
+}
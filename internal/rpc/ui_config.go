@@ -0,0 +1,88 @@
+package rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+)
+
+type PasswordPolicy struct {
+	MinLength                  uint `json:"minLength"`
+	MaxLength                  uint `json:"maxLength"`
+	MinNumbers                 uint `json:"minNumbers"`
+	MinSymbols                 uint `json:"minSymbols"`
+	MinUppercase               uint `json:"minUppercase"`
+	MinLowercase               uint `json:"minLowercase"`
+	MinCharacterClasses        uint `json:"minCharacterClasses"`
+	MaxRepeatedChars           uint `json:"maxRepeatedChars"`
+	MaxSequentialChars         uint `json:"maxSequentialChars"`
+	MinChangedChars            uint `json:"minChangedChars"`
+	PasswordCanIncludeUsername bool `json:"passwordCanIncludeUsername"`
+	AllowSamePassword          bool `json:"allowSamePassword"`
+}
+
+type Features struct {
+	ChangePassword bool `json:"changePassword"`
+}
+
+type UIConfig struct {
+	Policy   PasswordPolicy `json:"policy"`
+	Features Features       `json:"features"`
+}
+
+func PolicyFromOpts(opts *options.Opts) PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:                  opts.MinLength,
+		MaxLength:                  opts.MaxLength,
+		MinNumbers:                 opts.MinNumbers,
+		MinSymbols:                 opts.MinSymbols,
+		MinUppercase:               opts.MinUppercase,
+		MinLowercase:               opts.MinLowercase,
+		MinCharacterClasses:        opts.MinCharacterClasses,
+		MaxRepeatedChars:           opts.MaxRepeatedChars,
+		MaxSequentialChars:         opts.MaxSequentialChars,
+		MinChangedChars:            opts.MinChangedChars,
+		PasswordCanIncludeUsername: opts.PasswordCanIncludeUsername,
+		AllowSamePassword:          opts.AllowSamePassword,
+	}
+}
+
+// sendRevalidated answers with JSON that clients may cache but have to
+// revalidate, since the policy can be reloaded at runtime. The ETag is derived
+// from the body, so it changes with the policy.
+func sendRevalidated(c *fiber.Ctx, v any) error {
+	body, err := c.App().Config().JSONEncoder(v)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(body)
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderETag, `"`+hex.EncodeToString(sum[:16])+`"`)
+	if c.Fresh() {
+		return c.SendStatus(http.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	return c.Send(body)
+}
+
+// UIConfig serves the configuration the frontend needs to render itself.
+func (h *Handler) UIConfig(c *fiber.Ctx) error {
+	return sendRevalidated(c, UIConfig{
+		Policy: PolicyFromOpts(h.policy.Load()),
+		Features: Features{
+			ChangePassword: h.opts.ChangePasswordEnabled,
+		},
+	})
+}
+
+// Policy serves only the password policy, for clients that validate
+// passwords themselves.
+func (h *Handler) Policy(c *fiber.Ctx) error {
+	return sendRevalidated(c, PolicyFromOpts(h.policy.Load()))
+}
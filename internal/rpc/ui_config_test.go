@@ -0,0 +1,107 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestUIConfig(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinLength = 12
+	opts.PasswordCanIncludeUsername = true
+
+	app := fiber.New()
+	app.Get("/api/v1/ui-config", newHandler(t, opts, &mockLDAP{}).UIConfig)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/ui-config", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var config rpc.UIConfig
+	if err := json.NewDecoder(res.Body).Decode(&config); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	if config.Policy != rpc.PolicyFromOpts(opts) {
+		t.Errorf("expected policy %+v, got %+v", rpc.PolicyFromOpts(opts), config.Policy)
+	}
+	if config.Policy.MinLength != 12 || !config.Policy.PasswordCanIncludeUsername {
+		t.Errorf("policy does not reflect options: %+v", config.Policy)
+	}
+	if !config.Features.ChangePassword {
+		t.Error("expected change-password to be enabled")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	opts := defaultOpts()
+	opts.MinLength = 14
+	opts.MinSymbols = 2
+
+	app := fiber.New()
+	app.Get("/api/policy", newHandler(t, opts, &mockLDAP{}).Policy)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/policy", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cacheControl := res.Header.Get(fiber.HeaderCacheControl); cacheControl != "no-cache" {
+		t.Errorf("expected policy to be revalidated, got Cache-Control %q", cacheControl)
+	}
+
+	var policy rpc.PasswordPolicy
+	if err := json.NewDecoder(res.Body).Decode(&policy); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	if policy != rpc.PolicyFromOpts(opts) {
+		t.Errorf("expected policy %+v, got %+v", rpc.PolicyFromOpts(opts), policy)
+	}
+	if policy.MinLength != 14 || policy.MinSymbols != 2 {
+		t.Errorf("policy does not reflect options: %+v", policy)
+	}
+}
+
+func TestPolicyRevalidation(t *testing.T) {
+	fetch := func(opts *options.Opts, etag string) *http.Response {
+		t.Helper()
+
+		app := fiber.New()
+		app.Get("/api/policy", newHandler(t, opts, &mockLDAP{}).Policy)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/policy", nil)
+		if etag != "" {
+			req.Header.Set(fiber.HeaderIfNoneMatch, etag)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		return res
+	}
+
+	etag := fetch(defaultOpts(), "").Header.Get(fiber.HeaderETag)
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	if res := fetch(defaultOpts(), etag); res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status 304 for an unchanged policy, got %d", res.StatusCode)
+	}
+
+	opts := defaultOpts()
+	opts.MinLength = 16
+	if res := fetch(opts, etag); res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 for a changed policy, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/i18n"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/strength"
+)
+
+type ValidatePasswordRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type ValidatePasswordResponse struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+	// Score is the estimated strength of the password from 0 (too guessable)
+	// to 4 (very unguessable).
+	Score       int      `json:"score"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// ValidatePassword checks a password against the password policy without
+// changing anything, so the frontend can show feedback while typing. It never
+// contacts the LDAP server.
+func (h *Handler) ValidatePassword(c *fiber.Ctx) error {
+	var body ValidatePasswordRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(ValidatePasswordResponse{
+			Valid:  false,
+			Errors: []string{err.Error()},
+		})
+	}
+
+	errs := ValidateNewPasswordAll(body.Password, body.Username, h.policy.Load())
+	locale := localeFor(c)
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, i18n.Localize(err, locale))
+	}
+
+	score, suggestions := strength.Estimate(body.Password, body.Username)
+	for i := range suggestions {
+		suggestions[i] = locale.Translate(suggestions[i])
+	}
+
+	return c.JSON(ValidatePasswordResponse{
+		Valid:       len(errs) == 0,
+		Errors:      messages,
+		Score:       score,
+		Suggestions: suggestions,
+	})
+}
@@ -0,0 +1,58 @@
+package rpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
+)
+
+func TestValidatePassword(t *testing.T) {
+	client := &mockLDAP{}
+
+	app := fiber.New()
+	app.Post("/api/validate-password", newHandler(t, defaultOpts(), client).ValidatePassword)
+
+	cases := []struct {
+		Password       string
+		ExpectedValid  bool
+		ExpectedErrors int
+		MinScore       int
+	}{
+		{Password: "Tr0ub4dor&3", ExpectedValid: true, ExpectedErrors: 0, MinScore: 2},
+		// Too short, no number, no symbol and no uppercase letter.
+		{Password: "abc", ExpectedValid: false, ExpectedErrors: 4, MinScore: 0},
+	}
+
+	for _, c := range cases {
+		raw, _ := json.Marshal(rpc.ValidatePasswordRequest{Username: "jdoe", Password: c.Password})
+
+		req := httptest.NewRequest(http.MethodPost, "/api/validate-password", strings.NewReader(string(raw)))
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var body rpc.ValidatePasswordResponse
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		if body.Valid != c.ExpectedValid || len(body.Errors) != c.ExpectedErrors {
+			t.Errorf("expected valid=%t with %d errors for %q, got %+v", c.ExpectedValid, c.ExpectedErrors, c.Password, body)
+		}
+		if body.Score < c.MinScore || (c.MinScore == 0 && (body.Score != 0 || len(body.Suggestions) == 0)) {
+			t.Errorf("unexpected score %d with suggestions %v for %q", body.Score, body.Suggestions, c.Password)
+		}
+	}
+
+	if len(client.changed) != 0 {
+		t.Error("expected validation to never change a password")
+	}
+}
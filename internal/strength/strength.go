@@ -0,0 +1,171 @@
+// Package strength estimates how hard a password is to guess, similar to
+// zxcvbn but much simpler. It only looks at the password itself and works
+// without network access.
+package strength
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+const (
+	MinScore = 0
+	MaxScore = 4
+
+	SuggestLonger     = "use a longer password"
+	SuggestMixClasses = "mix letters, numbers and symbols"
+	SuggestNotCommon  = "avoid common passwords and simple variations of them"
+	SuggestNoUsername = "avoid using your username"
+	SuggestNoPatterns = "avoid repeated characters and sequences like \"abc\""
+)
+
+// Bits of entropy from which on a password reaches a score, starting with a
+// score of 1.
+var thresholds = []float64{28, 36, 60, 80}
+
+// Guessable common passwords are assumed to be found within this many bits.
+const commonPasswordBits = 10
+
+var unleet = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"@", "a",
+	"$", "s",
+	"!", "i",
+)
+
+func charsetSize(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+
+	return float64(size)
+}
+
+func classes(password string) int {
+	amount := 0
+	for _, present := range []bool{
+		validators.MinLowercaseLettersInString(password, 1),
+		validators.MinUppercaseLettersInString(password, 1),
+		validators.MinNumbersInString(password, 1),
+		validators.MinSymbolsInString(password, 1),
+	} {
+		if present {
+			amount++
+		}
+	}
+
+	return amount
+}
+
+// continues reports whether b repeats a or continues a sequence with it.
+func continues(a, b rune) bool {
+	if a == b {
+		return true
+	}
+
+	sameKind := (unicode.IsDigit(a) && unicode.IsDigit(b)) || (unicode.IsLetter(a) && unicode.IsLetter(b))
+
+	return sameKind && (b-a == 1 || a-b == 1)
+}
+
+// bits estimates the entropy of value. Characters that repeat or continue a
+// sequence add almost nothing, since guessers try these patterns early.
+func bits(value string, charset float64) (total float64, patterns bool) {
+	perChar := math.Log2(charset)
+
+	runes := []rune(strings.ToLower(value))
+	for i, c := range runes {
+		if i > 0 && continues(runes[i-1], c) {
+			total++
+			patterns = true
+
+			continue
+		}
+
+		total += perChar
+	}
+
+	return total, patterns
+}
+
+// Estimate returns a score from 0 (too guessable) to 4 (very unguessable)
+// for password and suggestions how to improve it.
+func Estimate(password, username string) (score int, suggestions []string) {
+	if password == "" {
+		return MinScore, []string{SuggestLonger}
+	}
+
+	charset := charsetSize(password)
+	lower := strings.ToLower(password)
+
+	entropy, patterns := bits(password, charset)
+	if patterns {
+		suggestions = append(suggestions, SuggestNoPatterns)
+	}
+
+	// A common password with a few digits or symbols around it is only as
+	// strong as those additions.
+	base := strings.TrimFunc(lower, func(c rune) bool { return !unicode.IsLetter(c) })
+	switch {
+	case validators.IsCommonPassword(lower) || validators.IsCommonPassword(unleet.Replace(lower)):
+		entropy = math.Min(entropy, commonPasswordBits)
+		suggestions = append(suggestions, SuggestNotCommon)
+	case len(base) >= 4 && validators.IsCommonPassword(unleet.Replace(base)):
+		rest, _ := bits(strings.Replace(lower, base, "", 1), charset)
+		entropy = math.Min(entropy, commonPasswordBits+rest)
+		suggestions = append(suggestions, SuggestNotCommon)
+	}
+
+	if len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		rest, _ := bits(strings.Replace(lower, strings.ToLower(username), "", 1), charset)
+		entropy = math.Min(entropy, commonPasswordBits+rest)
+		suggestions = append(suggestions, SuggestNoUsername)
+	}
+
+	for _, threshold := range thresholds {
+		if entropy >= threshold {
+			score++
+		}
+	}
+
+	if score < MaxScore {
+		if len([]rune(password)) < 12 {
+			suggestions = append(suggestions, SuggestLonger)
+		}
+		if classes(password) < 3 {
+			suggestions = append(suggestions, SuggestMixClasses)
+		}
+	}
+
+	return score, suggestions
+}
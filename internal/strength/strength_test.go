@@ -0,0 +1,49 @@
+package strength_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/strength"
+)
+
+func TestEstimateWeak(t *testing.T) {
+	for _, password := range []string{"", "password", "P@ssw0rd", "Password123!", "aaaaaaaa", "abcdefgh", "12345678", "jdoe2024"} {
+		if score, _ := strength.Estimate(password, "jdoe"); score > 1 {
+			t.Errorf("expected %q to score at most 1, got %d", password, score)
+		}
+	}
+}
+
+func TestEstimateStrong(t *testing.T) {
+	for _, password := range []string{"correct horse battery staple", "v9#Lq2!xTz@8mW", "Gx7$kP2q!Rm4vN9w"} {
+		if score, suggestions := strength.Estimate(password, "jdoe"); score < 4 {
+			t.Errorf("expected %q to score 4, got %d (%v)", password, score, suggestions)
+		}
+	}
+}
+
+func TestEstimateSuggestions(t *testing.T) {
+	cases := []struct {
+		Password string
+		Expected string
+	}{
+		{Password: "Password123!", Expected: strength.SuggestNotCommon},
+		{Password: "xjdoex", Expected: strength.SuggestNoUsername},
+		{Password: "Kq!aaaa", Expected: strength.SuggestNoPatterns},
+		{Password: "qwzx", Expected: strength.SuggestLonger},
+		{Password: "qwzxkvbnmplr", Expected: strength.SuggestMixClasses},
+	}
+
+	for _, c := range cases {
+		if _, suggestions := strength.Estimate(c.Password, "jdoe"); !slices.Contains(suggestions, c.Expected) {
+			t.Errorf("expected suggestions for %q to contain %q, got %v", c.Password, c.Expected, suggestions)
+		}
+	}
+}
+
+func BenchmarkEstimate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		strength.Estimate("Tr0ub4dor&3-correct-horse", "jdoe")
+	}
+}
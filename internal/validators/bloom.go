@@ -0,0 +1,169 @@
+package validators
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// BloomFilter is a set of SHA-1 password hashes (as published by Have I Been
+// Pwned) that can answer membership queries without network access.
+//
+// A bloom filter never produces false negatives, but it may produce false
+// positives: a password that was never breached can be reported as breached.
+// The false-positive rate depends on the size of the filter and the amount of
+// hashes it was built from, so operators should size their filter accordingly.
+//
+// The on-disk format is the number of bits m and the number of hash functions k
+// as big-endian uint64s, followed by the bit set itself, (m+7)/8 bytes with bit
+// i in byte i/8 at position i%8. The k bit positions of a SHA-1 hash are
+// (h1 + i*h2) mod m for i < k, where h1 and h2 are its first two 8 byte words
+// read as big-endian uint64s. cmd/bloom-filter builds such a file.
+type BloomFilter struct {
+	bits []byte
+	m    uint64
+	k    uint64
+}
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter")
+
+func NewBloomFilter(m, k uint64) *BloomFilter {
+	return &BloomFilter{
+		bits: make([]byte, (m+7)/8),
+		m:    m,
+		k:    k,
+	}
+}
+
+// NewBloomFilterFor sizes a filter for n hashes, so that a password that isn't
+// in it is reported with the false positive rate p.
+func NewBloomFilterFor(n uint64, p float64) *BloomFilter {
+	m := uint64(math.Ceil(-float64(max(n, 1)) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(max(1, math.Round(float64(m)/float64(max(n, 1))*math.Ln2)))
+
+	return NewBloomFilter(m, k)
+}
+
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var header [16]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	m, k := binary.BigEndian.Uint64(header[:8]), binary.BigEndian.Uint64(header[8:])
+	if m == 0 || k == 0 || m > math.MaxUint64-7 {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	// Check the size before allocating, so a corrupt header can't make us
+	// allocate an arbitrary amount of memory.
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(fi.Size()) != uint64(len(header))+(m+7)/8 {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	b := NewBloomFilter(m, k)
+
+	if _, err := io.ReadFull(f, b.bits); err != nil {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	return b, nil
+}
+
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	var header [16]byte
+	binary.BigEndian.PutUint64(header[:8], b.m)
+	binary.BigEndian.PutUint64(header[8:], b.k)
+
+	n, err := w.Write(header[:])
+	if err != nil {
+		return int64(n), err
+	}
+
+	n2, err := w.Write(b.bits)
+	return int64(n + n2), err
+}
+
+// positions derives the bit positions for a hash via double hashing.
+func (b *BloomFilter) positions(hash [sha1.Size]byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(hash[:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16])
+
+	positions := make([]uint64, b.k)
+	for i := uint64(0); i < b.k; i++ {
+		positions[i] = (h1 + i*h2) % b.m
+	}
+
+	return positions
+}
+
+func (b *BloomFilter) AddHash(hash [sha1.Size]byte) {
+	for _, p := range b.positions(hash) {
+		b.bits[p/8] |= 1 << (p % 8)
+	}
+}
+
+// AddHashes adds the hashes of a Have I Been Pwned SHA-1 download, one
+// "HASH:COUNT" line per password, and returns how many were added. Lines with
+// only a hash are accepted as well.
+func (b *BloomFilter) AddHashes(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+
+	var added, line uint64
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		hexHash, _, _ := strings.Cut(text, ":")
+
+		var hash [sha1.Size]byte
+		if len(hexHash) != hex.EncodedLen(sha1.Size) {
+			return added, fmt.Errorf("line %d: %q is not a SHA-1 hash", line, hexHash)
+		}
+		if _, err := hex.Decode(hash[:], []byte(hexHash)); err != nil {
+			return added, fmt.Errorf("line %d: %q is not a SHA-1 hash", line, hexHash)
+		}
+
+		b.AddHash(hash)
+		added++
+	}
+
+	return added, scanner.Err()
+}
+
+func (b *BloomFilter) Add(password string) {
+	b.AddHash(sha1.Sum([]byte(password)))
+}
+
+// MayContain reports whether the password is probably in the filter. A false
+// result means the password is definitely not in the filter.
+func (b *BloomFilter) MayContain(password string) bool {
+	for _, p := range b.positions(sha1.Sum([]byte(password))) {
+		if b.bits[p/8]&(1<<(p%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package validators_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := validators.NewBloomFilter(1<<16, 7)
+	b.Add("Password1!")
+	b.Add("Summer2024!")
+
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	loaded, err := validators.LoadBloomFilter(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: "Password1!", Expected: true},
+		{Input: "Summer2024!", Expected: true},
+		{Input: "correct horse battery staple", Expected: false},
+	}
+
+	for _, c := range cases {
+		actual := loaded.MayContain(c.Input)
+		if actual != c.Expected {
+			t.Errorf("expected %t for %q, got %t", c.Expected, c.Input, actual)
+		}
+	}
+}
+
+func TestLoadBloomFilterRejectsTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "truncated.bloom")
+	if err := os.WriteFile(path, []byte{0, 0, 0}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := validators.LoadBloomFilter(path); err == nil {
+		t.Error("expected an error for a truncated bloom filter")
+	}
+}
+
+func TestAddHashesRejectsInvalidLines(t *testing.T) {
+	b := validators.NewBloomFilterFor(10, 0.01)
+
+	for _, input := range []string{"not-a-hash:1\n", "ABCDEF:1\n", strings.Repeat("Z", 40) + ":1\n"} {
+		if _, err := b.AddHashes(strings.NewReader(input)); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package validators
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"strings"
+)
+
+// The list contains the 7,141 most common passwords, lowercased, as shipped
+// with zxcvbn (MIT licensed, https://github.com/dropbox/zxcvbn). That is the
+// whole frequency list of zxcvbn, it isn't cut.
+//
+//go:embed common_passwords.txt.gz
+var rawCommonPasswords []byte
+
+var commonPasswords = make(map[string]struct{})
+
+func init() {
+	r, err := gzip.NewReader(bytes.NewReader(rawCommonPasswords))
+	if err != nil {
+		panic("could not decompress the common passwords list: " + err.Error())
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			commonPasswords[line] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		panic("could not read the common passwords list: " + err.Error())
+	}
+}
+
+// IsCommonPassword reports whether the whole password, ignoring case, is one of
+// the most commonly used passwords.
+func IsCommonPassword(value string) bool {
+	_, exists := commonPasswords[strings.ToLower(value)]
+
+	return exists
+}
@@ -0,0 +1,34 @@
+package validators_test
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+func TestIsCommonPassword(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: "password", Expected: true},
+		{Input: "PassWord", Expected: true},
+		{Input: "qwerty", Expected: true},
+		// Only whole passwords match, not substrings.
+		{Input: "password-with-more", Expected: false},
+		{Input: "Xk#9vLq2!mW", Expected: false},
+	}
+
+	for _, c := range cases {
+		actual := validators.IsCommonPassword(c.Input)
+		if actual != c.Expected {
+			t.Errorf("expected %t for %q, got %t", c.Expected, c.Input, actual)
+		}
+	}
+}
+
+func BenchmarkIsCommonPassword(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		validators.IsCommonPassword("Xk#9vLq2!mW")
+	}
+}
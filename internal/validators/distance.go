@@ -0,0 +1,32 @@
+package validators
+
+// EditDistance returns the Levenshtein distance between a and b, the amount
+// of characters that have to be inserted, deleted or replaced to turn a into
+// b.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Only the previous row of the matrix is needed to compute the next one.
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
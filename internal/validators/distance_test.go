@@ -0,0 +1,29 @@
+package validators_test
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		A        string
+		B        string
+		Expected int
+	}{
+		{A: "Summer2023!", B: "Summer2023!", Expected: 0},
+		{A: "Summer2023!", B: "Summer2024!", Expected: 1},
+		{A: "Summer2023!", B: "Summer2023!!", Expected: 1},
+		{A: "Summer2023!", B: "ummer2023!", Expected: 1},
+		{A: "Sommer2023!", B: "Winter2024?", Expected: 6},
+		{A: "", B: "abc", Expected: 3},
+		{A: "Passwört1", B: "Passwort1", Expected: 1},
+	}
+
+	for _, c := range cases {
+		if actual := validators.EditDistance(c.A, c.B); actual != c.Expected {
+			t.Errorf("expected a distance of %d between %q and %q, got %d", c.Expected, c.A, c.B, actual)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package validators
+
+import "strings"
+
+// HasRepeatedRun reports whether value contains more than amount consecutive
+// identical characters, ignoring case.
+func HasRepeatedRun(value string, amount int) bool {
+	runes := []rune(strings.ToLower(value))
+
+	run := 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] != runes[i-1] {
+			run = 1
+			continue
+		}
+
+		run++
+		if run > amount {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sequenceStep returns 1 if b directly follows a, -1 if b directly precedes a
+// and 0 otherwise. Only letters and digits form sequences.
+func sequenceStep(a, b rune) int {
+	isDigit := func(c rune) bool { return c >= '0' && c <= '9' }
+	isLetter := func(c rune) bool { return c >= 'a' && c <= 'z' }
+
+	if !(isDigit(a) && isDigit(b)) && !(isLetter(a) && isLetter(b)) {
+		return 0
+	}
+
+	switch b - a {
+	case 1:
+		return 1
+	case -1:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// HasSequentialRun reports whether value contains more than amount ascending
+// or descending consecutive letters or digits, like "abc" or "987", ignoring
+// case.
+func HasSequentialRun(value string, amount int) bool {
+	runes := []rune(strings.ToLower(value))
+
+	run, direction := 1, 0
+	for i := 1; i < len(runes); i++ {
+		step := sequenceStep(runes[i-1], runes[i])
+
+		switch {
+		case step == 0:
+			run, direction = 1, 0
+			continue
+		case step == direction:
+			run++
+		default:
+			run, direction = 2, step
+		}
+
+		if run > amount {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,50 @@
+package validators_test
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+type RunTestCase struct {
+	Input    string
+	Arg      int
+	Expected bool
+}
+
+func TestHasRepeatedRun(t *testing.T) {
+	cases := []RunTestCase{
+		{Input: "Aaaaaaa1!", Arg: 3, Expected: true},
+		{Input: "aab1!", Arg: 2, Expected: false},
+		{Input: "aaab1!", Arg: 2, Expected: true},
+		{Input: "abab", Arg: 1, Expected: false},
+	}
+
+	for _, c := range cases {
+		actual := validators.HasRepeatedRun(c.Input, c.Arg)
+		if actual != c.Expected {
+			t.Errorf("expected %t for %q, got %t", c.Expected, c.Input, actual)
+		}
+	}
+}
+
+func TestHasSequentialRun(t *testing.T) {
+	cases := []RunTestCase{
+		{Input: "Abcdefg1!", Arg: 3, Expected: true},
+		{Input: "xcba!", Arg: 2, Expected: true},
+		{Input: "pw987!", Arg: 2, Expected: true},
+		{Input: "ab12", Arg: 2, Expected: false},
+		// Digits and letters don't continue each other's sequences.
+		{Input: "789a", Arg: 3, Expected: false},
+		// Changing direction starts a new run.
+		{Input: "abcba", Arg: 3, Expected: false},
+		{Input: "Tr0ub4dor&3", Arg: 2, Expected: false},
+	}
+
+	for _, c := range cases {
+		actual := validators.HasSequentialRun(c.Input, c.Arg)
+		if actual != c.Expected {
+			t.Errorf("expected %t for %q, got %t", c.Expected, c.Input, actual)
+		}
+	}
+}
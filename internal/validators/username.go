@@ -0,0 +1,49 @@
+package validators
+
+import "strings"
+
+// leetReplacer maps common character substitutions back to letters. Letters
+// that look alike, like "i" and "l", are mapped to the same letter, so either
+// substitution is found.
+var leetReplacer = strings.NewReplacer(
+	"4", "a", "@", "a",
+	"8", "b",
+	"3", "e",
+	"6", "g", "9", "g",
+	"1", "i", "!", "i", "|", "i", "l", "i",
+	"0", "o",
+	"5", "s", "$", "s",
+	"7", "t", "+", "t",
+	"2", "z",
+)
+
+// minUsernameVariantLength is the shortest username that is searched for in
+// passwords, shorter ones would reject too many unrelated passwords.
+const minUsernameVariantLength = 3
+
+func normalizeLeet(value string) string {
+	return leetReplacer.Replace(strings.ToLower(value))
+}
+
+func reverse(value string) string {
+	runes := []rune(value)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+// ContainsUsernameVariant reports whether password contains the username,
+// ignoring case and common leetspeak substitutions like "Adm1n" for "admin",
+// or the username reversed.
+func ContainsUsernameVariant(password, username string) bool {
+	if len([]rune(username)) < minUsernameVariantLength {
+		return false
+	}
+
+	normalized := normalizeLeet(password)
+	name := normalizeLeet(username)
+
+	return strings.Contains(normalized, name) || strings.Contains(normalized, reverse(name))
+}
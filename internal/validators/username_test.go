@@ -0,0 +1,31 @@
+package validators_test
+
+import (
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/validators"
+)
+
+func TestContainsUsernameVariant(t *testing.T) {
+	cases := []struct {
+		Password string
+		Username string
+		Expected bool
+	}{
+		{Password: "Admin2024!", Username: "admin", Expected: true},
+		{Password: "Adm1n2024!", Username: "admin", Expected: true},
+		{Password: "@dm!n2024", Username: "admin", Expected: true},
+		{Password: "xJD0E-42", Username: "jdoe", Expected: true},
+		{Password: "Nimda2024!", Username: "admin", Expected: true},
+		{Password: "N1md@2024!", Username: "admin", Expected: true},
+		{Password: "Ma1lbox2024!", Username: "mailbox", Expected: true},
+		{Password: "Correct-Horse-7", Username: "admin", Expected: false},
+		{Password: "Bold-Night-42", Username: "al", Expected: false},
+	}
+
+	for _, c := range cases {
+		if actual := validators.ContainsUsernameVariant(c.Password, c.Username); actual != c.Expected {
+			t.Errorf("expected %t for %q with username %q, got %t", c.Expected, c.Password, c.Username, actual)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 import {
   mustBeLongerThan,
+  mustChangeAtLeast,
+  mustBeShorterThan,
+  mustIncludeCharacterClasses,
   mustIncludeLowercase,
   mustIncludeNumbers,
   mustIncludeSymbols,
   mustIncludeUppercase,
   mustMatchNewPassword,
   mustNotBeEmpty,
+  mustNotHaveRepeatedRun,
+  mustNotHaveSequentialRun,
   mustNotIncludeUsername,
   mustNotMatchCurrentPassword,
   toggleValidator
@@ -13,11 +18,44 @@ import {
 
 type Opts = {
   minLength: number;
+  maxLength: number;
   minNumbers: number;
   minSymbols: number;
   minUppercase: number;
   minLowercase: number;
+  minCharacterClasses: number;
+  maxRepeatedChars: number;
+  maxSequentialChars: number;
+  minChangedChars: number;
   passwordCanIncludeUsername: boolean;
+  allowSamePassword: boolean;
+  // Path prefix the server is mounted under, empty when served at the root.
+  basePath?: string;
+};
+
+// Initializes the form with the policy the page was rendered with, then
+// fetches the current policy from the server, so the page picks up a reloaded
+// policy without being rendered again. The submit handler is attached before
+// the fetch, so the form is never submitted natively, which would send the
+// passwords to the server outside of the JSON-RPC call. If the fetch fails, the
+// rendered policy stays in effect. The base path defaults to the one the page
+// was rendered with.
+export const initFromConfig = async (
+  initial: Opts,
+  basePath = initial.basePath ?? document.documentElement.dataset.basePath ?? ""
+) => {
+  init({ ...initial, basePath });
+
+  try {
+    const res = await fetch(`${basePath}/api/v1/ui-config`);
+    if (!res.ok) throw new Error(`Could not load configuration: ${res.status}`);
+
+    const config = (await res.json()) as { policy: Opts };
+
+    init({ ...config.policy, basePath });
+  } catch (e) {
+    console.error("Using the policy the page was rendered with:", e);
+  }
 };
 
 export const init = (opts: Opts) => {
@@ -45,12 +83,17 @@ export const init = (opts: Opts) => {
       [
         mustNotBeEmpty,
         mustBeLongerThan(opts.minLength),
-        mustNotMatchCurrentPassword,
+        toggleValidator(mustBeShorterThan(opts.maxLength), opts.maxLength > 0),
+        toggleValidator(mustNotMatchCurrentPassword, !opts.allowSamePassword),
+        toggleValidator(mustChangeAtLeast(opts.minChangedChars), opts.minChangedChars > 0),
         toggleValidator(mustNotIncludeUsername, !opts.passwordCanIncludeUsername),
-        mustIncludeNumbers(opts.minNumbers),
-        mustIncludeSymbols(opts.minSymbols),
-        mustIncludeUppercase(opts.minUppercase),
-        mustIncludeLowercase(opts.minLowercase)
+        toggleValidator(mustIncludeNumbers(opts.minNumbers), opts.minCharacterClasses === 0),
+        toggleValidator(mustIncludeSymbols(opts.minSymbols), opts.minCharacterClasses === 0),
+        toggleValidator(mustIncludeUppercase(opts.minUppercase), opts.minCharacterClasses === 0),
+        toggleValidator(mustIncludeLowercase(opts.minLowercase), opts.minCharacterClasses === 0),
+        toggleValidator(mustIncludeCharacterClasses(opts.minCharacterClasses), opts.minCharacterClasses > 0),
+        toggleValidator(mustNotHaveRepeatedRun(opts.maxRepeatedChars), opts.maxRepeatedChars > 0),
+        toggleValidator(mustNotHaveSequentialRun(opts.maxSequentialChars), opts.maxSequentialChars > 0)
       ]
     ],
     ["new2", [mustNotBeEmpty, mustMatchNewPassword]]
@@ -145,7 +188,7 @@ export const init = (opts: Opts) => {
     toggleFields(false);
 
     try {
-      const res = await fetch("/api/rpc", {
+      const res = await fetch(`${opts.basePath ?? ""}/api/rpc`, {
         method: "POST",
         headers: {
           "Content-Type": "application/json"
@@ -164,7 +207,7 @@ export const init = (opts: Opts) => {
         try {
           const parsed = JSON.parse(body);
 
-          err = parsed.data[0];
+          err = parsed.data.join("\n");
         } catch (e) {}
 
         throw new Error(`An error occurred: ${err}`);
@@ -34,6 +34,8 @@ if (!submitButton) throw new Error("Could not find submit button element");
 export const mustNotBeEmpty = (v: string) => (v.length === 0 ? "The input must not be empty" : "");
 export const mustBeLongerThan = (minLength: number) => (v: string) =>
   v.length < minLength ? `The input must be at least ${minLength} ${pluralize("character", minLength)} long` : "";
+export const mustBeShorterThan = (maxLength: number) => (v: string) =>
+  v.length > maxLength ? `The input must be at most ${maxLength} ${pluralize("character", maxLength)} long` : "";
 export const mustIncludeNumbers = (amount: number) => (v: string) =>
   v.split("").filter((c) => !isNaN(+c)).length < amount
     ? `The input must include at least ${amount} ${pluralize("number", amount)}`
@@ -50,6 +52,70 @@ export const mustIncludeLowercase = (amount: number) => (v: string) =>
   v.split("").filter((c) => c === c.toLowerCase() && c !== c.toUpperCase()).length < amount
     ? `The input must include at least ${amount} lowercase ${pluralize("character", amount)}`
     : "";
+export const mustIncludeCharacterClasses = (amount: number) => (v: string) => {
+  const classes: [string, string][] = [
+    ["numbers", mustIncludeNumbers(1)(v)],
+    ["symbols", mustIncludeSymbols(1)(v)],
+    ["uppercase characters", mustIncludeUppercase(1)(v)],
+    ["lowercase characters", mustIncludeLowercase(1)(v)]
+  ];
+  const missing = classes.filter(([, error]) => error !== "").map(([name]) => name);
+
+  return classes.length - missing.length < amount
+    ? `The input must include at least ${amount} of the character classes numbers, symbols, uppercase and lowercase characters, it is missing ${missing.join(", ")}`
+    : "";
+};
+
+const sequenceStep = (a: string, b: string) => {
+  const isDigit = (c: string) => c >= "0" && c <= "9";
+  const isLetter = (c: string) => c >= "a" && c <= "z";
+  if (!(isDigit(a) && isDigit(b)) && !(isLetter(a) && isLetter(b))) return 0;
+
+  const step = b.charCodeAt(0) - a.charCodeAt(0);
+
+  return step === 1 || step === -1 ? step : 0;
+};
+
+export const mustNotHaveRepeatedRun = (amount: number) => (v: string) => {
+  const chars = v.toLowerCase().split("");
+
+  let run = 1;
+  for (let i = 1; i < chars.length; i++) {
+    run = chars[i] === chars[i - 1] ? run + 1 : 1;
+
+    if (run > amount)
+      return `The input must not repeat the same character more than ${amount} ${pluralize("time", amount)} in a row`;
+  }
+
+  return "";
+};
+export const mustNotHaveSequentialRun = (amount: number) => (v: string) => {
+  const chars = v.toLowerCase().split("");
+
+  let run = 1;
+  let direction = 0;
+  for (let i = 1; i < chars.length; i++) {
+    const step = sequenceStep(chars[i - 1] ?? "", chars[i] ?? "");
+
+    if (step === 0) {
+      run = 1;
+      direction = 0;
+      continue;
+    }
+
+    if (step === direction) {
+      run++;
+    } else {
+      run = 2;
+      direction = step;
+    }
+
+    if (run > amount)
+      return `The input must not contain sequences like "abc" or "987" longer than ${amount} ${pluralize("character", amount)}`;
+  }
+
+  return "";
+};
 
 export const mustMatchNewPassword = (v: string) => {
   const passwordInput = form.querySelector<HTMLInputElement>(`#new input`);
@@ -63,11 +129,43 @@ export const mustNotMatchCurrentPassword = (v: string) => {
 
   return passwordInput.value === v ? "The input must not match the current password" : "";
 };
+// Levenshtein distance, the amount of characters that have to be inserted,
+// deleted or replaced to turn a into b. Same as EditDistance on the server.
+const editDistance = (a: string, b: string) => {
+  const ca = Array.from(a);
+  const cb = Array.from(b);
+
+  let prev = Array.from({ length: cb.length + 1 }, (_, j) => j);
+  for (let i = 1; i <= ca.length; i++) {
+    const cur = [i];
+
+    for (let j = 1; j <= cb.length; j++) {
+      const cost = ca[i - 1] === cb[j - 1] ? 0 : 1;
+
+      cur[j] = Math.min((prev[j] ?? 0) + 1, (cur[j - 1] ?? 0) + 1, (prev[j - 1] ?? 0) + cost);
+    }
+
+    prev = cur;
+  }
+
+  return prev[cb.length] ?? 0;
+};
+
+export const mustChangeAtLeast = (amount: number) => (v: string) => {
+  const passwordInput = form.querySelector<HTMLInputElement>(`#current input`);
+  if (!passwordInput) throw new Error("Could not find password input element");
+
+  return editDistance(passwordInput.value, v) < amount
+    ? `The input must differ from the current password in at least ${amount} ${pluralize("character", amount)}`
+    : "";
+};
 export const mustNotIncludeUsername = (v: string) => {
   const passwordInput = form.querySelector<HTMLInputElement>(`#username input`);
   if (!passwordInput) throw new Error("Could not find username input element");
 
-  return v.includes(passwordInput.value) ? "The input must not include the username" : "";
+  const username = passwordInput.value.toLowerCase();
+
+  return username !== "" && v.toLowerCase().includes(username) ? "The input must not include the username" : "";
 };
 
 export const toggleValidator = (validate: (v: string) => string, enabled: boolean) => (v: string) =>
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	_ "embed"
 	"html/template"
+	"strings"
 
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
 )
@@ -31,8 +32,21 @@ func MakeInputOpts(name, placeholder, type_, autocomplete string) InputOpts {
 	}
 }
 
+// withBasePath prefixes paths on this server with the base path and leaves
+// other URLs alone.
+func withBasePath(basePath, url string) string {
+	if !strings.HasPrefix(url, "/") || strings.HasPrefix(url, "//") {
+		return url
+	}
+
+	return basePath + url
+}
+
 func RenderIndex(opts *options.Opts) ([]byte, error) {
-	funcs := template.FuncMap{"InputOpts": MakeInputOpts}
+	funcs := template.FuncMap{
+		"InputOpts": MakeInputOpts,
+		"path":      func(url string) string { return withBasePath(opts.BasePath, url) },
+	}
 
 	tpl, err := template.New("index").Funcs(funcs).Parse(rawIndex)
 	if err != nil {
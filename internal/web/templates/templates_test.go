@@ -0,0 +1,95 @@
+package templates_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/web/templates"
+)
+
+func TestRenderIndexChangePasswordEnabled(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		index, err := templates.RenderIndex(&options.Opts{ChangePasswordEnabled: enabled})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if hasForm := bytes.Contains(index, []byte(`id="form"`)); hasForm != enabled {
+			t.Errorf("expected the form to be rendered only when enabled, enabled: %v, rendered: %v", enabled, hasForm)
+		}
+	}
+}
+
+func TestRenderIndexInlinePolicy(t *testing.T) {
+	index, err := templates.RenderIndex(&options.Opts{ChangePasswordEnabled: true, MinLength: 14, MinChangedChars: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The form is only ever posted, so the passwords can't end up in a URL
+	// if the script didn't attach its submit handler.
+	for _, expected := range []string{`id="form" method="post"`, `minLength: +"14"`, `minChangedChars: +"3"`} {
+		if !bytes.Contains(index, []byte(expected)) {
+			t.Errorf("expected the page to contain %s", expected)
+		}
+	}
+}
+
+func TestRenderIndexBranding(t *testing.T) {
+	index, err := templates.RenderIndex(&options.Opts{
+		AppName:      "ACME <Password> Portal",
+		LogoURL:      "https://cdn.example.com/logo.png?size=large&theme=dark",
+		PrimaryColor: "#ff0066",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"<title>ACME &lt;Password&gt; Portal</title>",
+		`src="https://cdn.example.com/logo.png?size=large&amp;theme=dark"`,
+		`<meta name="theme-color" content="#ff0066" />`,
+		`style="--color-primary: #ff0066"`,
+	}
+	for _, e := range expected {
+		if !bytes.Contains(index, []byte(e)) {
+			t.Errorf("expected the page to contain %q", e)
+		}
+	}
+}
+
+func TestRenderIndexBasePath(t *testing.T) {
+	index, err := templates.RenderIndex(&options.Opts{
+		ChangePasswordEnabled: true,
+		BasePath:              "/pwreset",
+		LogoURL:               "/static/logo.webp",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{
+		`href="/pwreset/static/styles.css"`,
+		`href="/pwreset/static/favicon.ico"`,
+		`src="/pwreset/static/logo.webp"`,
+		`import { initFromConfig } from "\/pwreset\/static\/js\/app.js";`,
+		`data-base-path="/pwreset"`,
+	} {
+		if !bytes.Contains(index, []byte(expected)) {
+			t.Errorf("expected the page to contain %s", expected)
+		}
+	}
+
+	if bytes.Contains(index, []byte(`"/static/`)) {
+		t.Error("expected no links without the base path")
+	}
+
+	index, err = templates.RenderIndex(&options.Opts{BasePath: "/pwreset", LogoURL: "https://cdn.example.com/logo.png"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Contains(index, []byte(`src="https://cdn.example.com/logo.png"`)) {
+		t.Error("expected external logo URLs not to be prefixed")
+	}
+}
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	EventPasswordChanged = "password-changed"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+	// if a secret is configured.
+	SignatureHeader = "X-Signature-SHA256"
+
+	queueSize = 100
+	attempts  = 3
+	backoff   = 500 * time.Millisecond
+)
+
+// Event is the payload posted to the webhook. It must never contain a
+// password.
+type Event struct {
+	Event     string    `json:"event"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier posts events to a webhook in the background, so slow or failing
+// receivers don't delay requests. Events are dropped when the queue is full
+// or the notifier is closed.
+type Notifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+	queue  chan Event
+	wg     sync.WaitGroup
+
+	// mu guards closed, so Notify doesn't send on the closed queue.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func New(url, secret string) *Notifier {
+	n := &Notifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 5 * time.Second},
+		queue:  make(chan Event, queueSize),
+	}
+
+	n.wg.Add(1)
+	go n.work()
+
+	return n
+}
+
+func (n *Notifier) Notify(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.closed {
+		slog.Warn("webhook notifier is closed, dropping event", "event", event.Event)
+		return
+	}
+
+	select {
+	case n.queue <- event:
+	default:
+		slog.Warn("webhook queue is full, dropping event", "event", event.Event)
+	}
+}
+
+// Close sends the queued events and stops the notifier. It is called on
+// shutdown, after the web server stopped, so events of the last requests
+// aren't lost. Events notified afterwards are dropped.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	n.wg.Wait()
+}
+
+func (n *Notifier) work() {
+	defer n.wg.Done()
+
+	for event := range n.queue {
+		if err := n.send(event); err != nil {
+			slog.Error("could not send event to webhook", "event", event.Event, "err", err)
+		}
+	}
+}
+
+// send posts the event and retries with an exponential backoff if the
+// receiver is unreachable or answers with a server error.
+func (n *Notifier) send(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(body)
+		if err == nil || attempt == attempts-1 {
+			return err
+		}
+
+		time.Sleep(backoff << attempt)
+	}
+}
+
+func (n *Notifier) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("webhook answered with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body, receivers can use it to
+// verify requests.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
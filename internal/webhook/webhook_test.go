@@ -0,0 +1,116 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/webhook"
+)
+
+type receiver struct {
+	mu        sync.Mutex
+	bodies    [][]byte
+	headers   []http.Header
+	failFirst int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+
+	if len(r.bodies) <= r.failFirst {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestNotifierPostsSignedPayload(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := webhook.New(server.URL, "s3cret")
+	n.Notify(webhook.Event{Event: webhook.EventPasswordChanged, Username: "jdoe", IP: "192.0.2.1"})
+	n.Close()
+
+	if len(rec.bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(rec.bodies))
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal(rec.bodies[0], &payload); err != nil {
+		t.Fatalf("could not decode payload: %v", err)
+	}
+	for _, key := range []string{"event", "username", "ip", "timestamp"} {
+		if _, ok := payload[key]; !ok {
+			t.Errorf("expected payload to contain %q, got %v", key, payload)
+		}
+	}
+	if len(payload) != 4 {
+		t.Errorf("expected payload to only contain 4 keys, got %v", payload)
+	}
+	if payload["event"] != webhook.EventPasswordChanged || payload["username"] != "jdoe" || payload["ip"] != "192.0.2.1" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+
+	expected := webhook.Sign([]byte("s3cret"), rec.bodies[0])
+	if sig := rec.headers[0].Get(webhook.SignatureHeader); sig != expected {
+		t.Errorf("expected signature %q, got %q", expected, sig)
+	}
+}
+
+func TestNotifierRetriesServerErrors(t *testing.T) {
+	rec := &receiver{failFirst: 1}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := webhook.New(server.URL, "")
+	n.Notify(webhook.Event{Event: webhook.EventPasswordChanged, Username: "jdoe"})
+	n.Close()
+
+	if len(rec.bodies) != 2 {
+		t.Fatalf("expected a retry after the server error, got %d requests", len(rec.bodies))
+	}
+	if sig := rec.headers[1].Get(webhook.SignatureHeader); sig != "" {
+		t.Errorf("expected no signature without a secret, got %q", sig)
+	}
+}
+
+func TestNotifierCloseSendsQueuedEvents(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := webhook.New(server.URL, "")
+	for i := 0; i < 10; i++ {
+		n.Notify(webhook.Event{Event: webhook.EventPasswordChanged, Username: "jdoe"})
+	}
+	n.Close()
+
+	if len(rec.bodies) != 10 {
+		t.Errorf("expected all 10 queued events to be sent on close, got %d requests", len(rec.bodies))
+	}
+}
+
+func TestNotifierDropsEventsAfterClose(t *testing.T) {
+	rec := &receiver{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n := webhook.New(server.URL, "")
+	n.Close()
+	n.Notify(webhook.Event{Event: webhook.EventPasswordChanged, Username: "jdoe"})
+	n.Close()
+
+	if len(rec.bodies) != 0 {
+		t.Errorf("expected no request after close, got %d", len(rec.bodies))
+	}
+}
@@ -1,53 +1,266 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/check"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/metrics"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/ratelimit"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/rpc"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/web/static"
 	"github.com/netresearch/ldap-selfservice-password-changer/internal/web/templates"
 )
 
+func rateLimitAlgorithm(opts *options.Opts) limiter.LimiterHandler {
+	if opts.RateLimitAlgorithm == options.RateLimitAlgorithmBucket {
+		return ratelimit.TokenBucket{}
+	}
+
+	return limiter.SlidingWindow{}
+}
+
+// compressionLevels maps the configurable compression levels to those of the
+// compress middleware. Disabled compression isn't in here, so the middleware
+// isn't used at all.
+var compressionLevels = map[string]compress.Level{
+	options.CompressionBestSpeed:       compress.LevelBestSpeed,
+	options.CompressionDefault:         compress.LevelDefault,
+	options.CompressionBestCompression: compress.LevelBestCompression,
+}
+
+func useCompression(app *fiber.App, opts *options.Opts) {
+	if level, ok := compressionLevels[opts.CompressionLevel]; ok {
+		app.Use(compress.New(compress.Config{
+			Level: level,
+		}))
+	}
+}
+
+// useExtraHeaders sets the configured headers on every response, after the
+// handlers ran so they take precedence.
+func useExtraHeaders(app *fiber.App, opts *options.Opts) {
+	if len(opts.ExtraHeaders) == 0 {
+		return
+	}
+
+	app.Use(func(c *fiber.Ctx) error {
+		err := c.Next()
+		for name, value := range opts.ExtraHeaders {
+			c.Set(name, value)
+		}
+
+		return err
+	})
+}
+
+// useStatic serves the embedded assets. The favicon is also served where
+// browsers look for it when a page doesn't link one.
+func useStatic(router fiber.Router, opts *options.Opts) {
+	root := http.FS(static.Static)
+	maxAge := int(opts.StaticMaxAge / time.Second)
+
+	router.Use("/static", filesystem.New(filesystem.Config{
+		Root:   root,
+		MaxAge: maxAge,
+	}))
+
+	router.Get("/favicon.ico", func(c *fiber.Ctx) error {
+		if maxAge > 0 {
+			c.Set(fiber.HeaderCacheControl, "public, max-age="+strconv.Itoa(maxAge))
+		}
+
+		return filesystem.SendFile(c, root, "favicon.ico")
+	})
+}
+
+func fiberConfig(opts *options.Opts) fiber.Config {
+	return fiber.Config{
+		AppName:      "netresearch/ldap-selfservice-password-changer",
+		BodyLimit:    int(opts.MaxBodyBytes),
+		ReadTimeout:  opts.RequestTimeout,
+		WriteTimeout: opts.RequestTimeout,
+	}
+}
+
+// serve runs the app until ctx is done. Then it stops accepting connections
+// and waits up to timeout for the running requests to finish.
+func serve(ctx context.Context, app *fiber.App, addr string, timeout time.Duration) error {
+	listening := make(chan struct{})
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		close(listening)
+		return nil
+	})
+
+	errs := make(chan error, 1)
+	go func() { errs <- app.Listen(addr) }()
+
+	// Shutting down before the app listens would leave it running.
+	select {
+	case err := <-errs:
+		return err
+	case <-listening:
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return app.ShutdownWithContext(shutdownCtx)
+}
+
 func main() {
-	opts := options.Parse()
+	opts, err := options.Parse()
+	if err != nil {
+		log.Fatalf("err: %v", err)
+	}
+
+	slog.SetDefault(slog.New(opts.LogHandler(os.Stderr)))
+
+	if opts.Check {
+		if !check.Run(os.Stdout, check.All(opts)) {
+			os.Exit(1)
+		}
+
+		return
+	}
 
 	rpcHandler, err := rpc.New(opts)
 	if err != nil {
-		log.Fatalf("An error occurred during initialization: %v", err)
+		slog.Error("An error occurred during initialization", "err", err)
+		os.Exit(1)
 	}
 
 	index, err := templates.RenderIndex(opts)
 	if err != nil {
-		log.Fatalf("An error occurred during rendering the page: %v", err)
+		slog.Error("An error occurred during rendering the page", "err", err)
+		os.Exit(1)
 	}
 
-	app := fiber.New(fiber.Config{
-		AppName:   "netresearch/ldap-selfservice-password-changer",
-		BodyLimit: 4 * 1024,
-	})
+	var metricsServer *http.Server
+	if opts.MetricsEnabled {
+		metricsServer = metrics.NewServer(opts.MetricsAddress)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("could not start metrics server", "err", err)
+			}
+		}()
+	}
 
-	app.Use(compress.New(compress.Config{
-		Level: compress.LevelBestSpeed,
-	}))
+	app := fiber.New(fiberConfig(opts))
 
-	app.Use("/static", filesystem.New(filesystem.Config{
-		Root:   http.FS(static.Static),
-		MaxAge: 24 * 60 * 60,
-	}))
+	app.Use(rpc.RequestID())
+	useExtraHeaders(app, opts)
+
+	if opts.RequireHTTPS {
+		app.Use(rpcHandler.RequireHTTPS)
+	}
 
-	app.Get("/", func(c *fiber.Ctx) error {
+	useCompression(app, opts)
+
+	// All routes are served under the base path, which is empty unless a
+	// reverse proxy only forwards a subpath.
+	router := app.Group(opts.BasePath)
+
+	useStatic(router, opts)
+
+	router.Get("/", func(c *fiber.Ctx) error {
 		c.Set("Content-Type", fiber.MIMETextHTMLCharsetUTF8)
 		return c.Send(index)
 	})
 
-	app.Post("/api/rpc", rpcHandler.Handle)
+	router.Get("/api/v1/ui-config", rpcHandler.UIConfig)
+	router.Get("/api/policy", rpcHandler.Policy)
+	router.Get("/api/openapi.json", rpcHandler.OpenAPI)
+	if opts.ChangeRateLimitRequests > 0 {
+		router.Post("/api/rpc", limiter.New(limiter.Config{
+			Max:               int(opts.ChangeRateLimitRequests),
+			Expiration:        opts.ChangeRateLimitWindow,
+			Next:              rpcHandler.RateLimitExempt,
+			KeyGenerator:      rpcHandler.ClientIP,
+			LimiterMiddleware: rateLimitAlgorithm(opts),
+			LimitReached:      rpc.RateLimitReached,
+		}), rpcHandler.Handle)
+	} else {
+		router.Post("/api/rpc", rpcHandler.Handle)
+	}
+	if opts.ChangePasswordEnabled {
+		router.Post("/api/validate-password", limiter.New(limiter.Config{
+			Max:               60,
+			Expiration:        time.Minute,
+			Next:              rpcHandler.RateLimitExempt,
+			KeyGenerator:      rpcHandler.ClientIP,
+			LimiterMiddleware: rateLimitAlgorithm(opts),
+			LimitReached:      rpc.RateLimitReached,
+		}), rpcHandler.ValidatePassword)
+	}
+
+	// The expiry tells whether an account exists, so it is only offered
+	// when enabled.
+	if opts.PasswordExpiryEnabled {
+		router.Get("/api/password-expiry", limiter.New(limiter.Config{
+			Max:               60,
+			Expiration:        time.Minute,
+			Next:              rpcHandler.RateLimitExempt,
+			KeyGenerator:      rpcHandler.ClientIP,
+			LimiterMiddleware: rateLimitAlgorithm(opts),
+			LimitReached:      rpc.RateLimitReached,
+		}), rpcHandler.PasswordExpiry)
+	}
+
+	if opts.AdminToken != "" || opts.AdminTokenHash != "" {
+		// The limiter runs before the token is checked, so the token can't be
+		// guessed at full speed.
+		admin := router.Group("/admin", limiter.New(limiter.Config{
+			Max:               60,
+			Expiration:        time.Minute,
+			Next:              rpcHandler.RateLimitExempt,
+			KeyGenerator:      rpcHandler.ClientIP,
+			LimiterMiddleware: rateLimitAlgorithm(opts),
+			LimitReached:      rpc.RateLimitReached,
+		}), rpcHandler.RequireAdminToken)
 
-	if err := app.Listen(":3000"); err != nil {
-		log.Printf("err: could not start web server: %s", err)
+		admin.Get("/status", rpcHandler.Status)
+		admin.Post("/maintenance", rpcHandler.SetMaintenance)
+		admin.Post("/reload-policy", rpcHandler.ReloadPolicy)
+		admin.Get("/user-exists", rpcHandler.UserExists)
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := serve(ctx, app, ":3000", opts.RequestTimeout); err != nil {
+		slog.Error("could not serve", "err", err)
+	}
+
+	slog.Info("shutting down")
+
+	if metricsServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.RequestTimeout)
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("could not stop metrics server", "err", err)
+		}
+		cancel()
+	}
+
+	// Stops the background jobs and sends the queued webhook events.
+	rpcHandler.Close()
 }
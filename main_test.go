@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/netresearch/ldap-selfservice-password-changer/internal/options"
+	"github.com/valyala/fasthttp"
+)
+
+func TestFiberConfig(t *testing.T) {
+	opts := &options.Opts{MaxBodyBytes: 64, RequestTimeout: 30 * time.Second}
+
+	config := fiberConfig(opts)
+	if config.ReadTimeout != opts.RequestTimeout || config.WriteTimeout != opts.RequestTimeout {
+		t.Errorf("expected timeouts of %s, got %s and %s", opts.RequestTimeout, config.ReadTimeout, config.WriteTimeout)
+	}
+
+	app := fiber.New(config)
+	app.Post("/", func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) })
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 64))))
+	if err != nil || res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected a body of the maximum size to be accepted, got %v", err)
+	}
+
+	// app.Test returns the error of the server instead of its 413 response.
+	res, err = app.Test(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 65))))
+	switch {
+	case err != nil:
+		if !errors.Is(err, fasthttp.ErrBodyTooLarge) {
+			t.Errorf("expected an oversized body to exceed the limit, got %v", err)
+		}
+	case res.StatusCode != http.StatusRequestEntityTooLarge:
+		t.Errorf("expected an oversized body to be rejected, got %d", res.StatusCode)
+	}
+}
+
+func TestUseCompression(t *testing.T) {
+	cases := map[string]string{
+		options.CompressionDisabled:        "",
+		options.CompressionBestSpeed:       "gzip",
+		options.CompressionDefault:         "gzip",
+		options.CompressionBestCompression: "gzip",
+	}
+
+	for level, expected := range cases {
+		app := fiber.New()
+		useCompression(app, &options.Opts{CompressionLevel: level})
+		app.Get("/", func(c *fiber.Ctx) error { return c.SendString(strings.Repeat("password ", 1000)) })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if encoding := res.Header.Get("Content-Encoding"); encoding != expected {
+			t.Errorf("expected content encoding %q for level %s, got %q", expected, level, encoding)
+		}
+	}
+}
+
+func TestUseExtraHeaders(t *testing.T) {
+	app := fiber.New()
+	useExtraHeaders(app, &options.Opts{ExtraHeaders: map[string]string{
+		"X-Permitted-Cross-Domain-Policies": "none",
+		"Cache-Control":                     "no-store",
+	}})
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Set("Cache-Control", "public, max-age=300")
+		return c.SendStatus(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/", "/missing"} {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if value := res.Header.Get("X-Permitted-Cross-Domain-Policies"); value != "none" {
+			t.Errorf("%s: expected the extra header, got %q", path, value)
+		}
+		if value := res.Header.Get("Cache-Control"); value != "no-store" {
+			t.Errorf("%s: expected the extra header to override the handler, got %q", path, value)
+		}
+	}
+}
+
+func TestUseStatic(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:              "",
+		time.Hour:      "public, max-age=3600",
+		24 * time.Hour: "public, max-age=86400",
+	}
+
+	for maxAge, expected := range cases {
+		app := fiber.New()
+		useStatic(app, &options.Opts{StaticMaxAge: maxAge})
+
+		for _, path := range []string{"/favicon.ico", "/static/favicon.ico"} {
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected status 200, got %d", path, res.StatusCode)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != "image/x-icon" {
+				t.Errorf("%s: expected content type image/x-icon, got %q", path, contentType)
+			}
+			if cacheControl := res.Header.Get("Cache-Control"); cacheControl != expected {
+				t.Errorf("%s: expected cache control %q for %s, got %q", path, expected, maxAge, cacheControl)
+			}
+		}
+	}
+}
+
+func TestServeShutsDownWhenDone(t *testing.T) {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- serve(ctx, app, "127.0.0.1:0", time.Second) }()
+
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected serve to return after the context is done")
+	}
+}
@@ -15,7 +15,12 @@ const config = {
     })
   ],
   theme: {
-    extend: {}
+    extend: {
+      colors: {
+        // The <html> element sets --color-primary from the --primary-color option.
+        primary: "var(--color-primary)"
+      }
+    }
   }
 };
 